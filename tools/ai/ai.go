@@ -8,15 +8,15 @@ package ai
 
 import (
 	stdContext "context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"maps"
 	"reflect"
 	"slices"
+	"strings"
 	"sync"
-	"time"
-
-	"github.com/goplus/spx/v2/pkg/spx"
 )
 
 // GopPackage indicates that this package is a XGo package.
@@ -30,28 +30,108 @@ var Break = errors.New("break interaction")
 //
 // A zero-value Player is ready to use.
 type Player struct {
-	mu                sync.RWMutex
-	interactionCond   *sync.Cond
-	interactionActive bool
-	role              string
-	roleContext       map[string]any
-	commands          map[string]commandInfo
-	errorHandler      func(error)
-	history           []Turn
-	archivedHistory   string
-	archiveInProgress bool
-}
-
-// knowledgeBase returns the knowledge base used for AI interactions.
+	mu                 sync.RWMutex
+	interactionCond    *sync.Cond
+	interactionActive  bool
+	queueMode          QueueMode
+	queueLen           int
+	queueSeq           uint64
+	role               string
+	roleContext        map[string]any
+	knowledge          map[string]any
+	customTransport    Transport
+	customMemoryStore  MemoryStore
+	customTokenizer    Tokenizer
+	customRateLimiter  *RateLimiter
+	customExecutor     Executor
+	customScheduler    *Scheduler
+	priority           int
+	policy             InteractionPolicy
+	commands           map[string]commandInfo
+	errorHandler       func(error)
+	textHandler        func(string)
+	turnStartHandler   func(int)
+	responseHandler    func(Response)
+	commandHandler     func(string, map[string]any, *CommandResult)
+	sequenceEndHandler func(EndReason)
+	archiveHandler     func(ArchiveEvent)
+	history            []Turn
+	historyTokens      []int
+	historyTokensGen   uint64
+	archivedHistory    string
+	archiveInProgress  bool
+	archiveDiscarded   bool
+	archiveCancel      stdContext.CancelFunc
+}
+
+// knowledgeBase returns the knowledge base used for AI interactions. It is
+// the [DefaultKnowledgeBase] with the player's own knowledge layered on top:
+// the player's keys win, and nested maps are merged recursively. The caller
+// must hold p.mu.
 func (p *Player) knowledgeBase() map[string]any {
-	return DefaultKnowledgeBase()
+	return mergeKnowledgeBase(DefaultKnowledgeBase(), p.knowledge)
+}
+
+// SetKnowledge sets the player's own knowledge, which is only known to this
+// player. It is layered on top of [DefaultKnowledgeBase] when interacting with
+// the AI: the player's keys win, and nested maps are merged recursively.
+//
+// SetKnowledge__0 replaces all of the player's knowledge, while
+// SetKnowledge__1 sets a single entry.
+func (p *Player) SetKnowledge__0(knowledge map[string]any) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.knowledge = knowledge
+}
+func (p *Player) SetKnowledge__1(key string, value any) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	knowledge := make(map[string]any, len(p.knowledge)+1)
+	maps.Copy(knowledge, p.knowledge)
+	knowledge[key] = value
+	p.knowledge = knowledge
 }
 
-// transport returns the [Transport] instance used for AI communication.
+// transport returns the [Transport] instance used for AI communication. It
+// falls back to [DefaultTransport] if no custom one is set via
+// [Player.SetTransport]. The caller must hold p.mu.
 func (p *Player) transport() Transport {
+	if p.customTransport != nil {
+		return p.customTransport
+	}
 	return DefaultTransport()
 }
 
+// SetTransport sets a custom [Transport] for this player, overriding
+// [DefaultTransport]. It resets to [DefaultTransport] if nil is provided.
+//
+// It is safe to call SetTransport while the player is thinking. The change
+// takes effect from the next transport call.
+func (p *Player) SetTransport(t Transport) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.customTransport = t
+}
+
+// interactionPolicy returns the effective [InteractionPolicy] for AI
+// interactions. Fields not set via [Player.SetInteractionPolicy] fall back to
+// [DefaultInteractionPolicy]. The caller must hold p.mu.
+func (p *Player) interactionPolicy() InteractionPolicy {
+	return p.policy.withFallback(DefaultInteractionPolicy())
+}
+
+// SetInteractionPolicy sets the [InteractionPolicy] for this player. Unset
+// fields, i.e., zero or negative ones, fall back to
+// [DefaultInteractionPolicy].
+//
+// It is safe to call SetInteractionPolicy while the player is thinking. The
+// change takes effect from the next interaction sequence.
+func (p *Player) SetInteractionPolicy(policy InteractionPolicy) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.policy = policy
+}
+
 // SetRole defines the character/persona that the AI should adopt during
 // interactions. The optional context provides extra context about the role.
 func (p *Player) SetRole__0(role string, context map[string]any) {
@@ -85,7 +165,10 @@ func PlayerOnCmd_(p *Player, cmd any, handler any) {
 	}
 
 	id := typeName
-	spec := extractCommandSpec(typ)
+	info, err := newCommandInfo(typ, handler)
+	if err != nil {
+		panic(fmt.Sprintf("AI command %s has invalid struct tags: %v", typeName, err))
+	}
 
 	p.mu.Lock()
 	defer p.mu.Unlock()
@@ -93,11 +176,7 @@ func PlayerOnCmd_(p *Player, cmd any, handler any) {
 	if p.commands == nil {
 		p.commands = make(map[string]commandInfo)
 	}
-	p.commands[id] = commandInfo{
-		typ:     typ,
-		handler: handler,
-		spec:    spec,
-	}
+	p.commands[id] = info
 }
 
 // Think sends a message to the AI and processes its response. The optional
@@ -107,36 +186,153 @@ func PlayerOnCmd_(p *Player, cmd any, handler any) {
 // on command execution results until the AI signals completion (no command) or
 // an [Break] is encountered, or a critical error occurs.
 func (p *Player) Think__0(msg string, context map[string]any) {
-	spx.ExecuteNative(func(ctx stdContext.Context, owner any) {
-		p.think(ctx, owner, msg, context)
-	})
+	p.ThinkResult__0(msg, context)
 }
 func (p *Player) Think__1(msg string) {
 	p.Think__0(msg, nil)
 }
 
-func (p *Player) think(ctx stdContext.Context, owner any, msg string, context map[string]any) {
-	const (
-		transportTimeout     = 45 * time.Second       // Timeout for each transport call.
-		maxTransportAttempts = 3                      // Maximum number of transport attempts per call.
-		maxTurns             = 20                     // Maximum number of turns in a single call to prevent infinite loops.
-		backoffBase          = 100 * time.Millisecond // Base time for exponential backoff calculation.
-		backoffCap           = 2 * time.Second        // Maximum backoff time cap.
-		rateLimitWaitTimeout = 2 * time.Minute        // Maximum wait time for rate limiting.
-	)
+// ThinkResult is like [Player.Think__0], but also returns the [Outcome] of the
+// interaction sequence, so the game can branch on what the AI actually did.
+//
+// Errors are reported both in [Outcome.Err] and to the handler registered via
+// [Player.OnErr__0].
+func (p *Player) ThinkResult__0(msg string, context map[string]any) *Outcome {
+	var outcome *Outcome
+	p.executeNative(func(ctx stdContext.Context, owner any) {
+		outcome = p.think(ctx, owner, msg, context)
+		if outcome.Err != nil && !isDroppedByQueue(outcome.Err) {
+			p.handleError(owner, outcome.Err)
+		}
+	})
+	return outcome
+}
+func (p *Player) ThinkResult__1(msg string) *Outcome {
+	return p.ThinkResult__0(msg, nil)
+}
+
+// ThinkAsync is like [Player.ThinkResult__0], but returns immediately with a
+// [ThinkHandle] instead of blocking the caller until the interaction sequence
+// finishes. The handle can be used to cancel the sequence or wait for its
+// [Outcome].
+//
+// The sequence outlives the calling script, so the script can go on, e.g., to
+// animate the sprite while the player thinks. It only stops early when
+// canceled via [ThinkHandle.Cancel], which should be called when the sequence
+// is no longer wanted (e.g., the owner is destroyed). Errors other than
+// cancellation via [ThinkHandle.Cancel] are reported to the handler
+// registered via [Player.OnErr__0].
+func (p *Player) ThinkAsync__0(msg string, context map[string]any) *ThinkHandle {
+	p.mu.RLock()
+	executor := p.executor()
+	p.mu.RUnlock()
+
+	h := &ThinkHandle{executor: executor, done: make(chan struct{})}
+	executor.ExecuteNative(func(ctx stdContext.Context, owner any) {
+		// The context of the calling script is canceled as soon as the
+		// script finishes, so the sequence must not depend on it.
+		ctx, h.cancel = stdContext.WithCancelCause(stdContext.WithoutCancel(ctx))
+		go func() {
+			defer close(h.done)
+			defer h.cancel(nil)
+
+			h.outcome = p.think(ctx, owner, msg, context)
+			if h.outcome.Err != nil && !isDroppedByQueue(h.outcome.Err) && !errors.Is(stdContext.Cause(ctx), errThinkHandleCanceled) {
+				p.handleError(owner, h.outcome.Err)
+			}
+		}()
+	})
+	return h
+}
+func (p *Player) ThinkAsync__1(msg string) *ThinkHandle {
+	return p.ThinkAsync__0(msg, nil)
+}
+
+// errThinkHandleCanceled is the cancellation cause used by [ThinkHandle.Cancel].
+var errThinkHandleCanceled = errors.New("think canceled by handle")
+
+// ThinkHandle is a handle to an interaction sequence started by
+// [Player.ThinkAsync__0].
+type ThinkHandle struct {
+	executor Executor
+	cancel   stdContext.CancelCauseFunc
+	done     chan struct{}
+	outcome  *Outcome
+}
+
+// Cancel cancels the interaction sequence. It is a no-op if the sequence has
+// already finished.
+//
+// What is recorded in the player's history, so the AI knows about it in
+// subsequent interactions, depends on when the sequence is canceled:
+//   - While waiting for the AI, the turn is recorded as interrupted.
+//   - After the AI responded, the response is recorded, but none of its
+//     commands are executed.
+//   - While a command is executing, the command finishes and is recorded,
+//     but the remaining commands of the response are not executed and no
+//     further turn is started.
+func (h *ThinkHandle) Cancel() {
+	h.cancel(errThinkHandleCanceled)
+}
+
+// Done returns a channel that is closed when the interaction sequence finishes.
+func (h *ThinkHandle) Done() <-chan struct{} {
+	return h.done
+}
+
+// Wait waits for the interaction sequence to finish and returns its [Outcome].
+// It yields to the game engine while waiting, so other scripts keep running.
+//
+// It returns nil if the calling script is aborted before the sequence finishes.
+func (h *ThinkHandle) Wait() *Outcome {
+	var outcome *Outcome
+	h.executor.ExecuteNative(func(ctx stdContext.Context, owner any) {
+		select {
+		case <-h.done:
+			outcome = h.outcome
+		case <-ctx.Done():
+		}
+	})
+	return outcome
+}
+
+// think runs an interaction sequence and returns its [Outcome].
+func (p *Player) think(ctx stdContext.Context, owner any, msg string, context map[string]any) *Outcome {
+	if err := p.beginInteraction(); err != nil {
+		return &Outcome{Err: err}
+	}
 
-	p.beginInteraction()
+	// Dispatch the end of the sequence once the player is no longer busy, so
+	// the handler can start another sequence right away.
+	outcome := &Outcome{}
+	defer func() {
+		p.handleSequenceEnd(owner, outcome.EndReason)
+	}()
 	defer p.endInteraction()
 
+	// Manage history asynchronously once the sequence ends, however it ends.
+	// The context of the calling script is canceled as soon as the script
+	// finishes, so archiving runs on behalf of owner instead, which lives
+	// until the owner is destroyed or the game is reset.
+	defer func() {
+		go p.execute(owner, func(ctx stdContext.Context, owner any) {
+			p.executeNative(func(_ stdContext.Context, _ any) {
+				p.manageHistory(ctx, owner)
+			})
+		})
+	}()
+
+	p.mu.RLock()
+	policy := p.interactionPolicy()
+	p.mu.RUnlock()
+
 	var (
 		currentMsg     = msg
 		currentContext = context
-
-		hasExecutedAtLeastOneCommandInThisCall bool
 	)
-	for i := range maxTurns {
+	for i := range policy.MaxTurns {
 		// Prepare request.
-		p.mu.RLock()
+		p.mu.Lock()
 		currentRole := p.role
 		currentRoleContext := p.roleContext
 		currentHistory := slices.Clone(p.history)
@@ -150,7 +346,12 @@ func (p *Player) think(ctx stdContext.Context, owner any, msg string, context ma
 		}
 		currentKnowledgeBase := p.knowledgeBase()
 		currentTransport := p.transport()
-		p.mu.RUnlock()
+		currentTokenizer, currentTurnTokens := p.historyTokenCounts()
+		currentRateLimiter := p.rateLimiter()
+		currentScheduler := p.scheduler()
+		currentPriority := p.priority
+		currentTextHandler := p.textHandler
+		p.mu.Unlock()
 
 		request := Request{
 			Content:          currentMsg,
@@ -163,42 +364,85 @@ func (p *Player) think(ctx stdContext.Context, owner any, msg string, context ma
 			KnowledgeBase:    currentKnowledgeBase,
 			ContinuationTurn: i,
 		}
+		fitRequestToBudget(&request, currentTokenizer, policy.RequestTokenBudget, currentTurnTokens)
+		p.handleTurnStart(owner, i)
 
-		// Call AI transport with retries.
+		// Call AI transport with retries. Each attempt waits for the rate
+		// limiter, then for a slot in the scheduler, which is held only
+		// while calling the transport. Only the initial turn may be dropped
+		// by the scheduler, as commands of earlier turns already ran.
 		var (
 			resp     Response
 			lastErr  error
-			rateGate rateLimitGate
+			attempts int
 		)
-		for range backoffAttempts(ctx, maxTransportAttempts, backoffBase, backoffCap) {
-			waitCtx, waitCancel := stdContext.WithTimeout(ctx, rateLimitWaitTimeout)
-			waitErr := rateGate.Wait(waitCtx)
+		for range backoffAttempts(ctx, policy.MaxTransportAttempts, policy.BackoffBase, policy.BackoffCap) {
+			waitCtx, waitCancel := stdContext.WithTimeout(ctx, policy.RateLimitWaitTimeout)
+			waitErr := currentRateLimiter.Wait(waitCtx)
 			waitCancel()
 			if waitErr != nil {
-				lastErr = fmt.Errorf("aborted due to excessive rate limit wait time (%s)", rateLimitWaitTimeout)
+				lastErr = fmt.Errorf("%w (%s)", ErrRateLimitWaitExceeded, policy.RateLimitWaitTimeout)
+				break
+			}
+
+			release, schedErr := currentScheduler.acquire(ctx, currentPriority, i == 0)
+			if errors.Is(schedErr, ErrDropped) {
+				outcome.Err = schedErr
+				return outcome
+			}
+			if schedErr != nil {
+				lastErr = schedErr
 				break
 			}
 
-			timeoutCtx, cancel := stdContext.WithTimeout(ctx, transportTimeout)
-			resp, lastErr = currentTransport.Interact(timeoutCtx, request)
+			attempts++
+			timeoutCtx, cancel := stdContext.WithTimeout(ctx, policy.TransportTimeout)
+			if streamingTransport, ok := currentTransport.(StreamingTransport); ok && currentTextHandler != nil {
+				var text strings.Builder
+				texts := &textDispatcher{dispatch: func(text string) {
+					p.handleText(owner, currentTextHandler, text)
+				}}
+				resp, lastErr = streamingTransport.InteractStream(timeoutCtx, request, func(delta string) {
+					text.WriteString(delta)
+					texts.update(text.String())
+				})
+				texts.wait()
+			} else {
+				resp, lastErr = currentTransport.Interact(timeoutCtx, request)
+			}
 			cancel()
-			if lastErr == nil {
+			release()
+			if lastErr == nil || IsQuotaExceeded(lastErr) {
+				// Retrying is pointless until the quota window resets.
 				break
 			}
 
-			rateGate.Observe(lastErr)
+			currentRateLimiter.Observe(lastErr)
 		}
-		if err := ctx.Err(); err != nil {
-			p.handleError(owner, fmt.Errorf("ai interaction canceled: %w", err))
-			return
+		if err := ctx.Err(); err != nil && (lastErr != nil || attempts == 0) {
+			// Record the interrupted turn so the AI knows about it in
+			// subsequent interactions. A response that arrived before the
+			// cancellation is recorded below instead.
+			p.appendHistory(Turn{
+				RequestContent: request.Content,
+				RequestContext: request.Context,
+				IsInitial:      i == 0,
+				IsInterrupted:  true,
+			})
+
+			outcome.Err = fmt.Errorf("%w: %w", ErrCanceled, err)
+			return outcome
 		}
 		if lastErr != nil {
-			p.handleError(owner, fmt.Errorf("ai interaction failed after %d transport attempts: %w", maxTransportAttempts, lastErr))
-			return
+			outcome.Err = &TransportError{Attempts: attempts, Last: lastErr}
+			return outcome
 		}
+		outcome.Text = resp.Text
+		p.handleResponse(owner, resp)
 
 		// Process AI response.
-		if resp.CommandName == "" {
+		calls := resp.commandCalls()
+		if len(calls) == 0 {
 			// AI returned no command. This signifies the end of the current interaction
 			// sequence from AI's perspective. Record this "no command" turn.
 			noCmdTurn := Turn{
@@ -209,49 +453,67 @@ func (p *Player) think(ctx stdContext.Context, owner any, msg string, context ma
 			}
 			p.appendHistory(noCmdTurn)
 
-			if !hasExecutedAtLeastOneCommandInThisCall {
-				p.handleError(owner, errors.New("ai did not provide an initial command or any command during the interaction"))
+			if len(outcome.Commands) == 0 {
+				outcome.Err = ErrNoCommand
+				return outcome
 			}
-			return
+			outcome.EndReason = EndReasonNoCommand
+			return outcome
 		}
-		hasExecutedAtLeastOneCommandInThisCall = true
-
-		var executedResult *CommandResult
-		p.mu.RLock()
-		cmdInfo, ok := p.commands[resp.CommandName]
-		p.mu.RUnlock()
-		if ok {
-			var err error
-			executedResult, err = callCommandHandler(owner, cmdInfo, resp.CommandArgs)
+
+		// Execute commands in order, stopping at the first [Break] or failure.
+		executedResults := make([]*CommandResult, 0, len(calls))
+		for _, call := range calls {
+			if ctx.Err() != nil {
+				// Canceled, so don't execute the remaining commands.
+				break
+			}
+			executedResult, err := p.executeCommand(owner, call)
 			if err != nil {
-				p.handleError(owner, fmt.Errorf("failed to execute command %s: %w", resp.CommandName, err))
-				return
+				outcome.Err = fmt.Errorf("failed to execute command %s: %w", call.Name, err)
+				return outcome
 			}
-		} else {
-			// AI requested a command that is not registered by the game. This is an error
-			// from AI's behavior/request, report back via executedResult.
-			executedResult = &CommandResult{
-				Success:      false,
-				ErrorMessage: fmt.Sprintf("ai requested unknown command: %s", resp.CommandName),
-				IsBreak:      false,
+			executedResults = append(executedResults, executedResult)
+			p.handleCommand(owner, call, executedResult)
+			outcome.Commands = append(outcome.Commands, ExecutedCommand{
+				Name:   call.Name,
+				Args:   call.Args,
+				Result: executedResult,
+			})
+			if executedResult.IsBreak || !executedResult.Success {
+				break
 			}
 		}
 
-		// Update history and player's state.
+		// Update history and player's state. Commands without results were
+		// not executed.
 		currentTurn := Turn{
-			RequestContent:        request.Content,
-			RequestContext:        request.Context,
-			ResponseText:          resp.Text,
-			ResponseCommandName:   resp.CommandName,
-			ResponseCommandArgs:   resp.CommandArgs,
-			ExecutedCommandResult: executedResult,
-			IsInitial:             i == 0,
+			RequestContent:      request.Content,
+			RequestContext:      request.Context,
+			ResponseText:        resp.Text,
+			ResponseCommandName: calls[0].Name,
+			ResponseCommandArgs: calls[0].Args,
+			IsInitial:           i == 0,
+		}
+		if len(executedResults) > 0 {
+			currentTurn.ExecutedCommandResult = executedResults[0]
+		}
+		if len(calls) > 1 {
+			currentTurn.ResponseCommandCalls = calls
+			currentTurn.ExecutedCommandResults = executedResults
 		}
 		p.appendHistory(currentTurn)
 
 		// Check for [Break].
-		if executedResult.IsBreak {
-			return
+		if len(executedResults) > 0 && executedResults[len(executedResults)-1].IsBreak {
+			outcome.EndReason = EndReasonBreak
+			return outcome
+		}
+
+		// Don't start another turn if canceled.
+		if err := ctx.Err(); err != nil {
+			outcome.Err = fmt.Errorf("%w: %w", ErrCanceled, err)
+			return outcome
 		}
 
 		// Prepare for the next iteration of the loop. The AI will decide the next step
@@ -260,22 +522,61 @@ func (p *Player) think(ctx stdContext.Context, owner any, msg string, context ma
 		currentContext = nil
 	}
 
-	// Manage history asynchronously.
-	go p.manageHistory(ctx)
+	outcome.EndReason = EndReasonMaxTurns
+	outcome.Err = ErrMaxTurnsExceeded
+	return outcome
+}
+
+// executeCommand executes a single command call requested by the AI.
+func (p *Player) executeCommand(owner any, call CommandCall) (*CommandResult, error) {
+	p.mu.RLock()
+	cmdInfo, ok := p.commands[call.Name]
+	executor := p.executor()
+	p.mu.RUnlock()
+	if !ok {
+		// AI requested a command that is not registered by the game. This is an error
+		// from AI's behavior/request, report back via the result.
+		return &CommandResult{
+			Success:      false,
+			ErrorMessage: fmt.Sprintf("ai requested unknown command: %s", call.Name),
+			IsBreak:      false,
+		}, nil
+	}
+	return callCommandHandler(executor, owner, cmdInfo, call.Args)
 }
 
-// beginInteraction acquires exclusive access for the upcoming interaction sequence.
-func (p *Player) beginInteraction() {
+// beginInteraction acquires exclusive access for the upcoming interaction
+// sequence. It returns [ErrBusy] or [ErrSuperseded] if the sequence is
+// dropped due to the [QueueMode] of the player.
+func (p *Player) beginInteraction() error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
 	if p.interactionCond == nil {
 		p.interactionCond = sync.NewCond(&p.mu)
 	}
+	if p.interactionActive && p.queueMode == QueueRejectWhileBusy {
+		return ErrBusy
+	}
+
+	p.queueSeq++
+	seq := p.queueSeq
+	p.queueLen++
+	defer func() { p.queueLen-- }()
+	if p.queueMode == QueueLatestWins {
+		// Let queued sequences know they are superseded.
+		p.interactionCond.Broadcast()
+	}
 	for p.interactionActive {
 		p.interactionCond.Wait()
+		if p.queueMode == QueueLatestWins && seq != p.queueSeq {
+			// Pass the wakeup on in case it was meant for the newest one.
+			p.interactionCond.Signal()
+			return ErrSuperseded
+		}
 	}
 	p.interactionActive = true
+	return nil
 }
 
 // endInteraction releases exclusive access and wakes up any waiting sequence.
@@ -308,7 +609,7 @@ func (p *Player) handleError(owner any, err error) {
 	p.mu.RUnlock()
 
 	if handler != nil {
-		spx.Execute(owner, func(ctx stdContext.Context, owner any) {
+		p.execute(owner, func(ctx stdContext.Context, owner any) {
 			handler(err)
 		})
 		return
@@ -318,6 +619,220 @@ func (p *Player) handleError(owner any, err error) {
 	log.Printf("ai error: %v", err)
 }
 
+// OnText registers a handler that is called with the AI's response text as it
+// arrives, so the game can show it before the whole response is complete. The
+// handler receives the text received so far in the current turn. If more text
+// arrives while the handler is still running, the handler is called next with
+// the latest text only, and it always receives the complete text of the turn
+// before the response is handled.
+//
+// The handler is only called if the [Transport] implements
+// [StreamingTransport]. Otherwise, the text is only available once the whole
+// response is complete.
+func (p *Player) OnText(handler func(text string)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.textHandler = handler
+}
+
+// handleText dispatches the AI's response text received so far to the handler.
+func (p *Player) handleText(owner any, handler func(string), text string) {
+	p.execute(owner, func(ctx stdContext.Context, owner any) {
+		handler(text)
+	})
+}
+
+// textDispatcher dispatches the streamed text received so far on its own
+// goroutine, so a slow text handler doesn't hold up reading the stream. Texts
+// received while the handler is running are coalesced, and only the latest
+// one is dispatched next.
+type textDispatcher struct {
+	dispatch func(text string)
+
+	mu      sync.Mutex
+	wg      sync.WaitGroup
+	text    string
+	pending bool
+	running bool
+}
+
+// update sets the latest text and dispatches it unless a dispatch is already
+// running, which picks it up once done.
+func (d *textDispatcher) update(text string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.text = text
+	d.pending = true
+	if !d.running {
+		d.running = true
+		d.wg.Go(d.run)
+	}
+}
+
+// run dispatches the latest text until no newer text is pending.
+func (d *textDispatcher) run() {
+	for {
+		d.mu.Lock()
+		if !d.pending {
+			d.running = false
+			d.mu.Unlock()
+			return
+		}
+		text := d.text
+		d.pending = false
+		d.mu.Unlock()
+
+		d.dispatch(text)
+	}
+}
+
+// wait waits until the latest text is dispatched. It must not be called
+// concurrently with update.
+func (d *textDispatcher) wait() {
+	d.wg.Wait()
+}
+
+// OnTurnStart registers a handler that is called when a turn of an
+// interaction sequence starts, right before the request is sent to the AI.
+// The handler receives the zero-based turn number in the sequence, which is 0
+// for the turn of the message passed to [Player.Think__0].
+func (p *Player) OnTurnStart__0(handler func(turn int)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.turnStartHandler = handler
+}
+func (p *Player) OnTurnStart__1(handler func()) {
+	p.OnTurnStart__0(func(turn int) {
+		handler()
+	})
+}
+
+// handleTurnStart dispatches the start of a turn to the registered handler,
+// if any.
+func (p *Player) handleTurnStart(owner any, turn int) {
+	p.mu.RLock()
+	handler := p.turnStartHandler
+	p.mu.RUnlock()
+
+	if handler != nil {
+		p.execute(owner, func(ctx stdContext.Context, owner any) {
+			handler(turn)
+		})
+	}
+}
+
+// OnResponse registers a handler that is called with each complete response
+// received from the AI, before its commands are executed.
+func (p *Player) OnResponse(handler func(resp Response)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.responseHandler = handler
+}
+
+// handleResponse dispatches a response to the registered handler, if any.
+func (p *Player) handleResponse(owner any, resp Response) {
+	p.mu.RLock()
+	handler := p.responseHandler
+	p.mu.RUnlock()
+
+	if handler != nil {
+		p.execute(owner, func(ctx stdContext.Context, owner any) {
+			handler(resp)
+		})
+	}
+}
+
+// OnCommand registers a handler that is called after each command requested
+// by the AI is executed, including unknown commands and commands with invalid
+// arguments, whose result reports the failure.
+func (p *Player) OnCommand(handler func(name string, args map[string]any, result *CommandResult)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.commandHandler = handler
+}
+
+// handleCommand dispatches an executed command to the registered handler, if
+// any.
+func (p *Player) handleCommand(owner any, call CommandCall, result *CommandResult) {
+	p.mu.RLock()
+	handler := p.commandHandler
+	p.mu.RUnlock()
+
+	if handler != nil {
+		p.execute(owner, func(ctx stdContext.Context, owner any) {
+			handler(call.Name, call.Args, result)
+		})
+	}
+}
+
+// OnSequenceEnd registers a handler that is called when an interaction
+// sequence ends, however it ends. The handler receives the [EndReason]. If the
+// sequence ended due to an error, the error is reported to the handler
+// registered via [Player.OnErr__0] as well.
+//
+// It is not called for sequences dropped due to the [QueueMode] of the
+// player, as they never start.
+func (p *Player) OnSequenceEnd(handler func(reason EndReason)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sequenceEndHandler = handler
+}
+
+// handleSequenceEnd dispatches the end of a sequence to the registered
+// handler, if any.
+func (p *Player) handleSequenceEnd(owner any, reason EndReason) {
+	p.mu.RLock()
+	handler := p.sequenceEndHandler
+	p.mu.RUnlock()
+
+	if handler != nil {
+		p.execute(owner, func(ctx stdContext.Context, owner any) {
+			handler(reason)
+		})
+	}
+}
+
+// ArchiveEvent describes a history archiving operation of a [Player].
+type ArchiveEvent struct {
+	// TurnCount is the number of history turns that were archived.
+	TurnCount int
+
+	// Size is the total size in bytes of the JSON-encoded archived turns.
+	Size int
+
+	// ArchivedHistory is the archived history after the operation.
+	ArchivedHistory string
+
+	// Err is non-nil if [Transport.Archive] failed, in which case
+	// ArchivedHistory was produced by a local summarizer instead. The local
+	// summary lists the requests, responses and executed commands of the
+	// archived turns, without the understanding of the AI.
+	Err error
+}
+
+// OnArchive registers a handler that is called after the player's history is
+// archived, or fails to be archived. History is checked for archiving after
+// every interaction sequence, once it grows past the archive thresholds of
+// the [InteractionPolicy].
+func (p *Player) OnArchive(handler func(event ArchiveEvent)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.archiveHandler = handler
+}
+
+// handleArchive dispatches an archive event to the registered handler, if any.
+func (p *Player) handleArchive(owner any, event ArchiveEvent) {
+	p.mu.RLock()
+	handler := p.archiveHandler
+	p.mu.RUnlock()
+
+	if handler != nil {
+		p.execute(owner, func(ctx stdContext.Context, owner any) {
+			handler(event)
+		})
+	}
+}
+
 // appendHistory appends a new turn to the interaction history.
 func (p *Player) appendHistory(turn Turn) {
 	p.mu.Lock()
@@ -326,45 +841,51 @@ func (p *Player) appendHistory(turn Turn) {
 }
 
 // manageHistory checks if archiving is needed and performs it if necessary.
-func (p *Player) manageHistory(ctx stdContext.Context) {
-	const (
-		archiveTimeout       = 120 * time.Second      // Timeout for archive operation.
-		maxArchiveAttempts   = 3                      // Maximum number of archive attempts.
-		backoffBase          = 500 * time.Millisecond // Base time for exponential backoff.
-		backoffCap           = 5 * time.Second        // Maximum backoff time cap.
-		rateLimitWaitTimeout = 2 * time.Minute        // Maximum wait time for rate limiting.
-	)
+// Archiving is canceled once ctx is done, or if the history is replaced by
+// [Player.Restore] in the meantime.
+func (p *Player) manageHistory(ctx stdContext.Context, owner any) {
+	ctx, cancel := stdContext.WithCancel(ctx)
+	defer cancel()
 
 	// Prepare archive if needed.
-	turnsToArchive, existingArchive := p.prepareArchive()
+	turnsToArchive, existingArchive := p.prepareArchive(cancel)
 	if len(turnsToArchive) == 0 {
 		return
 	}
 
 	// Perform archive with retries.
+	p.mu.RLock()
 	transport := p.transport()
+	rateLimiter := p.rateLimiter()
+	policy := p.interactionPolicy()
+	p.mu.RUnlock()
 	var (
 		archived ArchivedHistory
 		lastErr  error
-		rateGate rateLimitGate
+		attempts int
 	)
-	for range backoffAttempts(ctx, maxArchiveAttempts, backoffBase, backoffCap) {
-		waitCtx, waitCancel := stdContext.WithTimeout(ctx, rateLimitWaitTimeout)
-		waitErr := rateGate.Wait(waitCtx)
+	for range backoffAttempts(ctx, policy.MaxArchiveAttempts, policy.ArchiveBackoffBase, policy.ArchiveBackoffCap) {
+		waitCtx, waitCancel := stdContext.WithTimeout(ctx, policy.RateLimitWaitTimeout)
+		waitErr := rateLimiter.Wait(waitCtx)
 		waitCancel()
 		if waitErr != nil {
-			lastErr = fmt.Errorf("aborted due to excessive rate limit wait time (%s)", rateLimitWaitTimeout)
+			lastErr = fmt.Errorf("%w (%s)", ErrRateLimitWaitExceeded, policy.RateLimitWaitTimeout)
 			break
 		}
 
-		archiveCtx, cancel := stdContext.WithTimeout(ctx, archiveTimeout)
+		attempts++
+		archiveCtx, cancel := stdContext.WithTimeout(ctx, policy.ArchiveTimeout)
 		archived, lastErr = transport.Archive(archiveCtx, turnsToArchive, existingArchive)
 		cancel()
-		if lastErr == nil {
+		if lastErr == nil || IsQuotaExceeded(lastErr) {
 			break
 		}
 
-		rateGate.Observe(lastErr)
+		rateLimiter.Observe(lastErr)
+	}
+	event := ArchiveEvent{
+		TurnCount: len(turnsToArchive),
+		Size:      historySize(turnsToArchive),
 	}
 	if err := ctx.Err(); err != nil {
 		log.Printf("archive history canceled: %v", err)
@@ -372,31 +893,45 @@ func (p *Player) manageHistory(ctx stdContext.Context) {
 		return
 	}
 	if lastErr != nil {
-		log.Printf("failed to archive history after %d attempts: %v", maxArchiveAttempts, lastErr)
-		p.cancelArchive()
-		return
+		// Fall back to the local summarizer, so history doesn't keep growing
+		// while the transport is unavailable.
+		log.Printf("failed to archive history after %d attempts, summarizing locally: %v", attempts, lastErr)
+		archived.Content = SummarizeLocally(turnsToArchive, existingArchive)
+		event.Err = fmt.Errorf("failed to archive history: %w", &TransportError{Attempts: attempts, Last: lastErr})
 	}
 
-	// Apply the archive result.
-	p.applyArchive(archived.Content, len(turnsToArchive))
+	// Apply the archive result. The owner may be gone once ctx is done, so
+	// the handler is skipped then.
+	if p.applyArchive(archived.Content, len(turnsToArchive)) && ctx.Err() == nil {
+		event.ArchivedHistory = archived.Content
+		p.handleArchive(owner, event)
+	}
 }
 
 // prepareArchive checks if archiving is needed and prepares the data for
 // archiving. It returns nil if archiving is not needed or already in progress.
-func (p *Player) prepareArchive() (turnsToArchive []Turn, existingArchive string) {
-	const (
-		threshold   = 30 // Trigger archive when history reaches this many turns.
-		minRetained = 15 // Keep at least this many most recent turns after archiving.
-	)
-
+// Otherwise, cancel is kept to cancel the archive in progress until the
+// archive is applied or canceled.
+func (p *Player) prepareArchive(cancel stdContext.CancelFunc) (turnsToArchive []Turn, existingArchive string) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	if len(p.history) < threshold || p.archiveInProgress {
+	policy := p.interactionPolicy()
+	if p.archiveInProgress {
+		return nil, ""
+	}
+	overTurnThreshold := len(p.history) >= policy.ArchiveThreshold
+	overByteThreshold := historySize(p.history) >= policy.ArchiveByteThreshold
+	if !overTurnThreshold && !overByteThreshold {
 		return nil, ""
 	}
 
-	// Ensure we keep at least minRetained turns.
+	// Ensure we keep at least policy.ArchiveMinRetained turns, or fewer if
+	// they don't fit in half of policy.ArchiveByteThreshold.
+	minRetained := policy.ArchiveMinRetained
+	if overByteThreshold {
+		minRetained = min(minRetained, retainedWithinSize(p.history, policy.ArchiveByteThreshold/2))
+	}
 	if len(p.history) <= minRetained {
 		return nil, ""
 	}
@@ -427,22 +962,75 @@ func (p *Player) prepareArchive() (turnsToArchive []Turn, existingArchive string
 
 	// Mark as in progress and prepare data.
 	p.archiveInProgress = true
+	p.archiveCancel = cancel
 	return slices.Clone(p.history[:boundary]), p.archivedHistory
 }
 
 // applyArchive updates the archived history and removes the archived turns.
-func (p *Player) applyArchive(archived string, turnCount int) {
+// It reports false if the archive was discarded because the history was
+// replaced while archiving.
+func (p *Player) applyArchive(archived string, turnCount int) bool {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
+	if p.archiveDiscarded {
+		// The history was replaced by [Player.Restore] while archiving.
+		p.endArchive()
+		return false
+	}
 	p.archivedHistory = archived
 	p.history = p.history[turnCount:]
-	p.archiveInProgress = false
+	if len(p.historyTokens) >= turnCount {
+		p.historyTokens = p.historyTokens[turnCount:]
+	} else {
+		p.historyTokens = nil
+	}
+	p.endArchive()
+	return true
 }
 
 // cancelArchive resets the archive in progress flag.
 func (p *Player) cancelArchive() {
 	p.mu.Lock()
 	defer p.mu.Unlock()
+	p.endArchive()
+}
+
+// endArchive resets the archive in progress state. The caller must hold p.mu.
+func (p *Player) endArchive() {
+	p.archiveCancel = nil
 	p.archiveInProgress = false
+	p.archiveDiscarded = false
+}
+
+// turnSize returns the size in bytes of the JSON-encoded turn.
+func turnSize(turn Turn) int {
+	b, err := json.Marshal(turn)
+	if err != nil {
+		return 0
+	}
+	return len(b)
+}
+
+// historySize returns the total size in bytes of the JSON-encoded turns.
+func historySize(turns []Turn) int {
+	var size int
+	for _, turn := range turns {
+		size += turnSize(turn)
+	}
+	return size
+}
+
+// retainedWithinSize returns the number of most recent turns whose total
+// size fits in maxSize. It returns at least 1 if there are any turns.
+func retainedWithinSize(turns []Turn, maxSize int) int {
+	var size, n int
+	for i := len(turns) - 1; i >= 0; i-- {
+		size += turnSize(turns[i])
+		if size > maxSize && n > 0 {
+			break
+		}
+		n++
+	}
+	return n
 }
@@ -1,12 +1,25 @@
 package ai
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"reflect"
 	"slices"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 )
 
+// newTestPlayer creates a [Player] whose transport answers requests with
+// interact.
+func newTestPlayer(interact func(ctx context.Context, req Request) (Response, error)) *Player {
+	p := &Player{}
+	p.SetTransport(&mockTransport{InteractFunc: interact})
+	return p
+}
+
 func TestPlayerAppendHistory(t *testing.T) {
 	for _, tt := range []struct {
 		name           string
@@ -95,6 +108,7 @@ func TestPlayerPrepareArchive(t *testing.T) {
 		history               []Turn
 		archivedHistory       string
 		archiveInProgress     bool
+		policy                InteractionPolicy
 		wantTurnsCount        int
 		wantExistingArchive   string
 		wantArchiveInProgress bool
@@ -145,15 +159,40 @@ func TestPlayerPrepareArchive(t *testing.T) {
 			wantExistingArchive:   "old",
 			wantArchiveInProgress: true,
 		},
+		{
+			name: "ByteThreshold",
+			history: func() []Turn {
+				history := makeHistory(6, []int{0, 3})
+				for i := range history {
+					history[i].RequestContent = strings.Repeat("x", 100)
+				}
+				return history
+			}(),
+			archivedHistory:       "large",
+			policy:                InteractionPolicy{ArchiveByteThreshold: 500},
+			wantTurnsCount:        3,
+			wantExistingArchive:   "large",
+			wantArchiveInProgress: true,
+		},
+		{
+			name:                  "CustomPolicy",
+			history:               makeHistory(12, []int{0, 4, 8}),
+			archivedHistory:       "custom",
+			policy:                InteractionPolicy{ArchiveThreshold: 10, ArchiveMinRetained: 5},
+			wantTurnsCount:        4,
+			wantExistingArchive:   "custom",
+			wantArchiveInProgress: true,
+		},
 	} {
 		t.Run(tt.name, func(t *testing.T) {
 			p := &Player{
 				history:           tt.history,
 				archivedHistory:   tt.archivedHistory,
 				archiveInProgress: tt.archiveInProgress,
+				policy:            tt.policy,
 			}
 
-			turns, existingArchive := p.prepareArchive()
+			turns, existingArchive := p.prepareArchive(nil)
 
 			if got, want := len(turns), tt.wantTurnsCount; got != want {
 				t.Errorf("got %d, want %d", got, want)
@@ -191,7 +230,7 @@ func TestPlayerPrepareArchive(t *testing.T) {
 		}
 
 		// Phase 1: Prepare.
-		turns, existingArchive := p.prepareArchive()
+		turns, existingArchive := p.prepareArchive(nil)
 		if turns == nil {
 			t.Fatal("expected successful prepare")
 		}
@@ -218,7 +257,7 @@ func TestPlayerPrepareArchive(t *testing.T) {
 		}
 
 		// Phase 3: Second prepare should fail (not enough turns).
-		turns2, _ := p.prepareArchive()
+		turns2, _ := p.prepareArchive(nil)
 		if turns2 != nil {
 			t.Error("second prepare should return nil (not enough turns)")
 		}
@@ -246,7 +285,7 @@ func TestPlayerPrepareArchive(t *testing.T) {
 
 		go func() {
 			defer wg.Done()
-			turns, _ := p.prepareArchive()
+			turns, _ := p.prepareArchive(nil)
 			if turns != nil {
 				p.applyArchive("concurrent_archive", len(turns))
 			}
@@ -429,7 +468,7 @@ func TestPlayerCancelArchive(t *testing.T) {
 		}
 
 		// Prepare.
-		turns, _ := p.prepareArchive()
+		turns, _ := p.prepareArchive(nil)
 		if turns == nil {
 			t.Fatal("expected successful prepare")
 		}
@@ -447,9 +486,807 @@ func TestPlayerCancelArchive(t *testing.T) {
 		}
 
 		// Should be able to prepare again.
-		turns2, _ := p.prepareArchive()
+		turns2, _ := p.prepareArchive(nil)
 		if turns2 == nil {
 			t.Error("should be able to prepare again after cancel")
 		}
 	})
 }
+
+func TestPlayerSetTransport(t *testing.T) {
+	originalTransport := DefaultTransport()
+	t.Cleanup(func() { SetDefaultTransport(originalTransport) })
+	defaultTransport := &mockTransport{}
+	SetDefaultTransport(defaultTransport)
+
+	p := &Player{}
+	if got, want := p.transport(), Transport(defaultTransport); got != want {
+		t.Errorf("got %p, want %p", got, want)
+	}
+
+	customTransport := &mockTransport{}
+	p.SetTransport(customTransport)
+	if got, want := p.transport(), Transport(customTransport); got != want {
+		t.Errorf("got %p, want %p", got, want)
+	}
+
+	p.SetTransport(nil)
+	if got, want := p.transport(), Transport(defaultTransport); got != want {
+		t.Errorf("got %p, want %p", got, want)
+	}
+}
+
+type NoopCmd struct{}
+
+func TestPlayerThinkPolicy(t *testing.T) {
+	t.Run("MaxTurns", func(t *testing.T) {
+		var interactCount int
+		p := newTestPlayer(func(ctx context.Context, req Request) (Response, error) {
+			interactCount++
+			return Response{CommandName: "NoopCmd"}, nil
+		})
+		p.SetInteractionPolicy(InteractionPolicy{MaxTurns: 3})
+		XGot_Player_XGox_OnCmd(p, func(cmd NoopCmd) error { return nil })
+
+		p.Think__1("hello")
+
+		if got, want := interactCount, 3; got != want {
+			t.Errorf("got %d, want %d", got, want)
+		}
+		if got, want := len(p.history), 3; got != want {
+			t.Errorf("got %d, want %d", got, want)
+		}
+	})
+
+	t.Run("MaxTransportAttempts", func(t *testing.T) {
+		var interactCount int
+		var gotErr error
+		p := newTestPlayer(func(ctx context.Context, req Request) (Response, error) {
+			interactCount++
+			return Response{}, errors.New("network down")
+		})
+		p.SetInteractionPolicy(InteractionPolicy{
+			MaxTransportAttempts: 2,
+			BackoffBase:          time.Nanosecond,
+			BackoffCap:           time.Nanosecond,
+		})
+		p.OnErr__0(func(err error) { gotErr = err })
+
+		p.Think__1("hello")
+
+		if got, want := interactCount, 2; got != want {
+			t.Errorf("got %d, want %d", got, want)
+		}
+		if gotErr == nil {
+			t.Fatal("expected error")
+		}
+		var transportErr *TransportError
+		if !errors.As(gotErr, &transportErr) {
+			t.Fatalf("got %T, want %T", gotErr, transportErr)
+		}
+		if got, want := transportErr.Attempts, 2; got != want {
+			t.Errorf("got %d, want %d", got, want)
+		}
+		if got, want := transportErr.Last.Error(), "network down"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+}
+
+type MoveCmd struct {
+	Steps int
+}
+
+func TestPlayerThinkResult(t *testing.T) {
+	for _, tt := range []struct {
+		name          string
+		responses     []Response
+		handler       func(cmd MoveCmd) error
+		wantText      string
+		wantCommands  int
+		wantEndReason EndReason
+		wantErr       error
+	}{
+		{
+			name: "NoCommand",
+			responses: []Response{
+				{Text: "moving", CommandName: "MoveCmd", CommandArgs: map[string]any{"Steps": 2.0}},
+				{Text: "done"},
+			},
+			wantText:      "done",
+			wantCommands:  1,
+			wantEndReason: EndReasonNoCommand,
+		},
+		{
+			name: "Break",
+			responses: []Response{
+				{Text: "moving", CommandName: "MoveCmd", CommandArgs: map[string]any{"Steps": 1.0}},
+			},
+			handler:       func(cmd MoveCmd) error { return Break },
+			wantText:      "moving",
+			wantCommands:  1,
+			wantEndReason: EndReasonBreak,
+		},
+		{
+			name: "MaxTurns",
+			responses: []Response{
+				{CommandName: "MoveCmd"},
+				{CommandName: "MoveCmd"},
+				{Text: "last", CommandName: "MoveCmd"},
+			},
+			wantText:      "last",
+			wantCommands:  3,
+			wantEndReason: EndReasonMaxTurns,
+			wantErr:       ErrMaxTurnsExceeded,
+		},
+		{
+			name: "NoInitialCommand",
+			responses: []Response{
+				{Text: "hmm"},
+			},
+			wantText:      "hmm",
+			wantEndReason: EndReasonError,
+			wantErr:       ErrNoCommand,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPlayer(func(ctx context.Context, req Request) (Response, error) {
+				return tt.responses[req.ContinuationTurn], nil
+			})
+			p.SetInteractionPolicy(InteractionPolicy{MaxTurns: 3})
+			handler := tt.handler
+			if handler == nil {
+				handler = func(cmd MoveCmd) error { return nil }
+			}
+			XGot_Player_XGox_OnCmd(p, handler)
+			var handledErr error
+			p.OnErr__0(func(err error) { handledErr = err })
+
+			outcome := p.ThinkResult__1("go")
+
+			if got, want := outcome.Text, tt.wantText; got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+			if got, want := len(outcome.Commands), tt.wantCommands; got != want {
+				t.Fatalf("got %d, want %d", got, want)
+			}
+			for _, cmd := range outcome.Commands {
+				if got, want := cmd.Name, "MoveCmd"; got != want {
+					t.Errorf("got %q, want %q", got, want)
+				}
+				if cmd.Result == nil {
+					t.Error("expected non-nil result")
+				}
+			}
+			if got, want := outcome.EndReason, tt.wantEndReason; got != want {
+				t.Errorf("got %v, want %v", got, want)
+			}
+			if tt.wantErr == nil {
+				if outcome.Err != nil {
+					t.Errorf("unexpected error %v", outcome.Err)
+				}
+			} else if got, want := outcome.Err, tt.wantErr; !errors.Is(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+			if got, want := handledErr, outcome.Err; got != want {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestPlayerThinkCommandHandlerPanics(t *testing.T) {
+	p := newTestPlayer(func(ctx context.Context, req Request) (Response, error) {
+		return Response{CommandName: "MoveCmd"}, nil
+	})
+	XGot_Player_XGox_OnCmd(p, func(cmd MoveCmd) error { panic("boom") })
+
+	outcome := p.ThinkResult__1("go")
+
+	if got, want := outcome.EndReason, EndReasonError; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	var panicErr *CommandHandlerPanicError
+	if !errors.As(outcome.Err, &panicErr) {
+		t.Fatalf("got %v, want %T", outcome.Err, panicErr)
+	}
+	if got, want := panicErr.CommandName, "MoveCmd"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := panicErr.Value, any("boom"); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestPlayerThinkAsync(t *testing.T) {
+	t.Run("Wait", func(t *testing.T) {
+		p := newTestPlayer(func(ctx context.Context, req Request) (Response, error) {
+			if req.ContinuationTurn == 0 {
+				return Response{CommandName: "NoopCmd"}, nil
+			}
+			return Response{Text: "done"}, nil
+		})
+		XGot_Player_XGox_OnCmd(p, func(cmd NoopCmd) error { return nil })
+
+		h := p.ThinkAsync__1("hello")
+		outcome := h.Wait()
+
+		select {
+		case <-h.Done():
+		default:
+			t.Error("expected done channel to be closed")
+		}
+		if outcome == nil {
+			t.Fatal("expected non-nil outcome")
+		}
+		if got, want := outcome.EndReason, EndReasonNoCommand; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		if got, want := outcome.Text, "done"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("Cancel", func(t *testing.T) {
+		started := make(chan struct{})
+		p := newTestPlayer(func(ctx context.Context, req Request) (Response, error) {
+			close(started)
+			<-ctx.Done()
+			return Response{}, ctx.Err()
+		})
+		var handledErr error
+		p.OnErr__0(func(err error) { handledErr = err })
+
+		h := p.ThinkAsync__0("hello", map[string]any{"x": 1})
+		<-started
+		h.Cancel()
+		outcome := h.Wait()
+
+		if outcome == nil {
+			t.Fatal("expected non-nil outcome")
+		}
+		if got, want := outcome.EndReason, EndReasonError; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		if !errors.Is(outcome.Err, context.Canceled) {
+			t.Errorf("got %v, want %v", outcome.Err, context.Canceled)
+		}
+		if !errors.Is(outcome.Err, ErrCanceled) {
+			t.Errorf("got %v, want %v", outcome.Err, ErrCanceled)
+		}
+		if handledErr != nil {
+			t.Errorf("got %v, want nil", handledErr)
+		}
+
+		if got, want := len(p.history), 1; got != want {
+			t.Fatalf("got %d, want %d", got, want)
+		}
+		if got, want := p.history[0], (Turn{
+			RequestContent: "hello",
+			RequestContext: map[string]any{"x": 1},
+			IsInitial:      true,
+			IsInterrupted:  true,
+		}); !reflect.DeepEqual(got, want) {
+			t.Errorf("got %#v, want %#v", got, want)
+		}
+
+		// Cancel after finish is a no-op.
+		h.Cancel()
+	})
+
+	t.Run("OutlivesScript", func(t *testing.T) {
+		p := newTestPlayer(func(ctx context.Context, req Request) (Response, error) {
+			if req.ContinuationTurn == 0 {
+				return Response{CommandName: "NoopCmd"}, nil
+			}
+			return Response{Text: "done"}, nil
+		})
+		p.SetExecutor(&scriptExecutor{})
+		XGot_Player_XGox_OnCmd(p, func(cmd NoopCmd) error { return nil })
+
+		outcome := p.ThinkAsync__1("hello").Wait()
+
+		if outcome == nil {
+			t.Fatal("expected non-nil outcome")
+		}
+		if outcome.Err != nil {
+			t.Errorf("unexpected error: %v", outcome.Err)
+		}
+	})
+
+	t.Run("CancelAfterResponse", func(t *testing.T) {
+		handles := make(chan *ThinkHandle, 1)
+		p := newTestPlayer(func(ctx context.Context, req Request) (Response, error) {
+			(<-handles).Cancel()
+			return Response{Text: "moving", CommandName: "MoveCmd", CommandArgs: map[string]any{"Steps": 1}}, nil
+		})
+		var moved bool
+		XGot_Player_XGox_OnCmd(p, func(cmd MoveCmd) error {
+			moved = true
+			return nil
+		})
+
+		h := p.ThinkAsync__1("hello")
+		handles <- h
+		outcome := h.Wait()
+
+		if !errors.Is(outcome.Err, ErrCanceled) {
+			t.Errorf("got %v, want %v", outcome.Err, ErrCanceled)
+		}
+		if moved {
+			t.Error("expected command not to be executed")
+		}
+		history := p.history
+		if got, want := len(history), 1; got != want {
+			t.Fatalf("got %d, want %d", got, want)
+		}
+		if got, want := history[0], (Turn{
+			RequestContent:      "hello",
+			ResponseText:        "moving",
+			ResponseCommandName: "MoveCmd",
+			ResponseCommandArgs: map[string]any{"Steps": 1},
+			IsInitial:           true,
+		}); !reflect.DeepEqual(got, want) {
+			t.Errorf("got %#v, want %#v", got, want)
+		}
+	})
+
+	t.Run("CancelDuringCommand", func(t *testing.T) {
+		var (
+			handles      = make(chan *ThinkHandle, 1)
+			interactions int
+			steps        []int
+		)
+		p := newTestPlayer(func(ctx context.Context, req Request) (Response, error) {
+			interactions++
+			return Response{CommandCalls: []CommandCall{
+				{Name: "MoveCmd", Args: map[string]any{"Steps": 1}},
+				{Name: "MoveCmd", Args: map[string]any{"Steps": 2}},
+			}}, nil
+		})
+		XGot_Player_XGox_OnCmd(p, func(cmd MoveCmd) error {
+			(<-handles).Cancel()
+			steps = append(steps, cmd.Steps)
+			return nil
+		})
+
+		h := p.ThinkAsync__1("hello")
+		handles <- h
+		outcome := h.Wait()
+
+		if !errors.Is(outcome.Err, ErrCanceled) {
+			t.Errorf("got %v, want %v", outcome.Err, ErrCanceled)
+		}
+		if got, want := steps, []int{1}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		if got, want := interactions, 1; got != want {
+			t.Errorf("got %d, want %d", got, want)
+		}
+		history := p.history
+		if got, want := len(history), 1; got != want {
+			t.Fatalf("got %d, want %d", got, want)
+		}
+		if got, want := len(history[0].ResponseCommandCalls), 2; got != want {
+			t.Errorf("got %d command calls, want %d", got, want)
+		}
+		if got, want := len(history[0].ExecutedCommandResults), 1; got != want {
+			t.Errorf("got %d results, want %d", got, want)
+		}
+	})
+}
+
+// scriptExecutor is an [Executor] that cancels the context of the calling
+// script as soon as fn returns, like a script that finishes right away.
+type scriptExecutor struct {
+	GoroutineExecutor
+}
+
+func (scriptExecutor) ExecuteNative(fn func(ctx context.Context, owner any)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	fn(ctx, nil)
+}
+
+func TestPlayerHooks(t *testing.T) {
+	p := newTestPlayer(func(ctx context.Context, req Request) (Response, error) {
+		if req.ContinuationTurn == 0 {
+			return Response{Text: "Let me move.", CommandName: "MoveCmd", CommandArgs: map[string]any{"Steps": 2.0}}, nil
+		}
+		return Response{Text: "Done."}, nil
+	})
+	XGot_Player_XGox_OnCmd(p, func(cmd MoveCmd) error { return nil })
+	var events []string
+	p.OnTurnStart__0(func(turn int) {
+		events = append(events, fmt.Sprintf("turn start %d", turn))
+	})
+	p.OnResponse(func(resp Response) {
+		events = append(events, "response "+resp.Text)
+	})
+	p.OnCommand(func(name string, args map[string]any, result *CommandResult) {
+		events = append(events, fmt.Sprintf("command %s %v %t", name, args["Steps"], result.Success))
+	})
+	p.OnSequenceEnd(func(reason EndReason) {
+		events = append(events, fmt.Sprintf("sequence end %s, thinking %t", reason, p.IsThinking()))
+	})
+
+	p.Think__1("hello")
+
+	if got, want := events, []string{
+		"turn start 0",
+		"response Let me move.",
+		"command MoveCmd 2 true",
+		"turn start 1",
+		"response Done.",
+		"sequence end no command, thinking false",
+	}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+}
+
+func TestPlayerOnText(t *testing.T) {
+	newTransport := func() *mockStreamingTransport {
+		return &mockStreamingTransport{
+			mockTransport: mockTransport{
+				InteractFunc: func(ctx context.Context, req Request) (Response, error) {
+					if req.ContinuationTurn == 0 {
+						return Response{Text: "Let me move.", CommandName: "NoopCmd"}, nil
+					}
+					return Response{Text: "Done."}, nil
+				},
+			},
+			InteractStreamFunc: func(ctx context.Context, req Request, onText func(delta string)) (Response, error) {
+				if req.ContinuationTurn == 0 {
+					onText("Let me ")
+					onText("move.")
+					return Response{Text: "Let me move.", CommandName: "NoopCmd"}, nil
+				}
+				onText("Done.")
+				return Response{Text: "Done."}, nil
+			},
+		}
+	}
+
+	t.Run("Streaming", func(t *testing.T) {
+		p := &Player{}
+		p.SetTransport(newTransport())
+		XGot_Player_XGox_OnCmd(p, func(cmd NoopCmd) error { return nil })
+		var texts []string
+		p.OnText(func(text string) { texts = append(texts, text) })
+
+		outcome := p.ThinkResult__1("hello")
+
+		if outcome.Err != nil {
+			t.Fatalf("unexpected error %v", outcome.Err)
+		}
+		// Intermediate texts may be coalesced, but the complete text of each
+		// turn is always dispatched.
+		if !slices.Contains(texts, "Let me move.") {
+			t.Errorf("got %#v, want it to contain %q", texts, "Let me move.")
+		}
+		if got, want := texts[len(texts)-1], "Done."; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("SlowHandler", func(t *testing.T) {
+		started := make(chan struct{})
+		release := make(chan struct{})
+		p := &Player{}
+		p.SetTransport(&mockStreamingTransport{
+			InteractStreamFunc: func(ctx context.Context, req Request, onText func(delta string)) (Response, error) {
+				onText("Let ")
+				<-started
+
+				// The handler is still busy, so these don't block and are
+				// coalesced into the latest text.
+				onText("me ")
+				onText("move.")
+				close(release)
+				return Response{Text: "Let me move."}, nil
+			},
+		})
+		var texts []string
+		p.OnText(func(text string) {
+			texts = append(texts, text)
+			if len(texts) == 1 {
+				close(started)
+				<-release
+			}
+		})
+
+		outcome := p.ThinkResult__1("hello")
+
+		if got, want := outcome.Text, "Let me move."; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+		if got, want := texts, []string{"Let ", "Let me move."}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %#v, want %#v", got, want)
+		}
+	})
+
+	t.Run("NonStreamingTransport", func(t *testing.T) {
+		p := &Player{}
+		p.SetTransport(&newTransport().mockTransport)
+		XGot_Player_XGox_OnCmd(p, func(cmd NoopCmd) error { return nil })
+		var texts []string
+		p.OnText(func(text string) { texts = append(texts, text) })
+
+		outcome := p.ThinkResult__1("hello")
+
+		if outcome.Err != nil {
+			t.Fatalf("unexpected error %v", outcome.Err)
+		}
+		if got, want := outcome.Text, "Done."; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+		if len(texts) != 0 {
+			t.Errorf("got %#v, want none", texts)
+		}
+	})
+}
+
+func TestPlayerThinkCommandCalls(t *testing.T) {
+	for _, tt := range []struct {
+		name          string
+		calls         []CommandCall
+		handler       func(cmd MoveCmd) error
+		wantSteps     []int
+		wantResults   int
+		wantEndReason EndReason
+	}{
+		{
+			name: "AllExecuted",
+			calls: []CommandCall{
+				{Name: "MoveCmd", Args: map[string]any{"Steps": 1.0}},
+				{Name: "MoveCmd", Args: map[string]any{"Steps": 2.0}},
+			},
+			wantSteps:     []int{1, 2},
+			wantResults:   2,
+			wantEndReason: EndReasonNoCommand,
+		},
+		{
+			name: "StopsAtBreak",
+			calls: []CommandCall{
+				{Name: "MoveCmd", Args: map[string]any{"Steps": 1.0}},
+				{Name: "MoveCmd", Args: map[string]any{"Steps": 2.0}},
+			},
+			handler: func(cmd MoveCmd) error {
+				return Break
+			},
+			wantSteps:     []int{1},
+			wantResults:   1,
+			wantEndReason: EndReasonBreak,
+		},
+		{
+			name: "StopsAtFailure",
+			calls: []CommandCall{
+				{Name: "MoveCmd", Args: map[string]any{"Steps": -1.0}},
+				{Name: "MoveCmd", Args: map[string]any{"Steps": 2.0}},
+			},
+			handler: func(cmd MoveCmd) error {
+				if cmd.Steps < 0 {
+					return errors.New("invalid steps")
+				}
+				return nil
+			},
+			wantSteps:     []int{-1},
+			wantResults:   1,
+			wantEndReason: EndReasonNoCommand,
+		},
+		{
+			name: "StopsAtUnknownCommand",
+			calls: []CommandCall{
+				{Name: "FlyCmd"},
+				{Name: "MoveCmd", Args: map[string]any{"Steps": 2.0}},
+			},
+			wantSteps:     nil,
+			wantResults:   1,
+			wantEndReason: EndReasonNoCommand,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPlayer(func(ctx context.Context, req Request) (Response, error) {
+				if req.ContinuationTurn == 0 {
+					return Response{CommandCalls: tt.calls}, nil
+				}
+				return Response{Text: "done"}, nil
+			})
+			var steps []int
+			XGot_Player_XGox_OnCmd(p, func(cmd MoveCmd) error {
+				steps = append(steps, cmd.Steps)
+				if tt.handler != nil {
+					return tt.handler(cmd)
+				}
+				return nil
+			})
+
+			outcome := p.ThinkResult__1("go")
+
+			if outcome.Err != nil {
+				t.Fatalf("unexpected error %v", outcome.Err)
+			}
+			if got, want := steps, tt.wantSteps; !reflect.DeepEqual(got, want) {
+				t.Errorf("got %#v, want %#v", got, want)
+			}
+			if got, want := outcome.EndReason, tt.wantEndReason; got != want {
+				t.Errorf("got %v, want %v", got, want)
+			}
+			if got, want := len(outcome.Commands), tt.wantResults; got != want {
+				t.Errorf("got %d, want %d", got, want)
+			}
+
+			turn := p.history[0]
+			if got, want := turn.ResponseCommandCalls, tt.calls; !reflect.DeepEqual(got, want) {
+				t.Errorf("got %#v, want %#v", got, want)
+			}
+			if got, want := len(turn.ExecutedCommandResults), tt.wantResults; got != want {
+				t.Errorf("got %d, want %d", got, want)
+			}
+			if got, want := turn.ResponseCommandName, tt.calls[0].Name; got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+			if got, want := turn.ExecutedCommandResult, turn.ExecutedCommandResults[0]; got != want {
+				t.Errorf("got %#v, want %#v", got, want)
+			}
+		})
+	}
+}
+
+func TestPlayerThinkArchive(t *testing.T) {
+	newPlayer := func(archiveFunc func(ctx context.Context, turns []Turn, existingArchive string) (ArchivedHistory, error)) (*Player, <-chan ArchiveEvent) {
+		p := &Player{}
+		p.SetTransport(&mockTransport{
+			InteractFunc: func(ctx context.Context, req Request) (Response, error) {
+				if req.ContinuationTurn == 0 {
+					return Response{CommandName: "NoopCmd"}, nil
+				}
+				return Response{Text: "Done."}, nil
+			},
+			ArchiveFunc: archiveFunc,
+		})
+		p.SetInteractionPolicy(InteractionPolicy{
+			ArchiveThreshold:   2,
+			ArchiveMinRetained: 1,
+			MaxArchiveAttempts: 1,
+		})
+		XGot_Player_XGox_OnCmd(p, func(cmd NoopCmd) error { return nil })
+		events := make(chan ArchiveEvent, 1)
+		p.OnArchive(func(event ArchiveEvent) { events <- event })
+		return p, events
+	}
+
+	t.Run("ArchivedAfterNoCommand", func(t *testing.T) {
+		p, events := newPlayer(func(ctx context.Context, turns []Turn, existingArchive string) (ArchivedHistory, error) {
+			return ArchivedHistory{Content: fmt.Sprintf("%d turns", len(turns))}, nil
+		})
+
+		// The first sequence has no complete sequence before the retained
+		// turns, so nothing is archived yet.
+		p.Think__1("first")
+		p.Think__1("second")
+
+		select {
+		case event := <-events:
+			if got, want := event.TurnCount, 2; got != want {
+				t.Errorf("got %d, want %d", got, want)
+			}
+			if got, want := event.ArchivedHistory, "2 turns"; got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+			if event.Size <= 0 {
+				t.Errorf("got %d, want positive size", event.Size)
+			}
+			if event.Err != nil {
+				t.Errorf("unexpected error %v", event.Err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for archive event")
+		}
+
+		p.mu.RLock()
+		defer p.mu.RUnlock()
+		if got, want := len(p.history), 2; got != want {
+			t.Errorf("got %d, want %d", got, want)
+		}
+		if got, want := p.archivedHistory, "2 turns"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("LocalFallback", func(t *testing.T) {
+		archiveErr := errors.New("archive unavailable")
+		p, events := newPlayer(func(ctx context.Context, turns []Turn, existingArchive string) (ArchivedHistory, error) {
+			return ArchivedHistory{}, archiveErr
+		})
+
+		p.Think__1("first")
+		p.Think__1("second")
+
+		wantArchive := "Asked: first\n  Ran NoopCmd(): succeeded\n  Replied: Done."
+		select {
+		case event := <-events:
+			if !errors.Is(event.Err, archiveErr) {
+				t.Errorf("got %v, want %v", event.Err, archiveErr)
+			}
+			if got, want := event.ArchivedHistory, wantArchive; got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for archive event")
+		}
+
+		p.mu.RLock()
+		defer p.mu.RUnlock()
+		if got, want := len(p.history), 2; got != want {
+			t.Errorf("got %d, want %d", got, want)
+		}
+		if got, want := p.archivedHistory, wantArchive; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+		if p.archiveInProgress {
+			t.Error("expected archiveInProgress to be false after fallback")
+		}
+	})
+
+	t.Run("CanceledWithOwner", func(t *testing.T) {
+		started := make(chan struct{})
+		p, events := newPlayer(func(ctx context.Context, turns []Turn, existingArchive string) (ArchivedHistory, error) {
+			close(started)
+			<-ctx.Done()
+			return ArchivedHistory{}, ctx.Err()
+		})
+		p.history = make([]Turn, 4)
+		p.history[0].IsInitial = true
+		p.history[2].IsInitial = true
+
+		// The context of the owner is canceled when it is destroyed or the
+		// game is reset.
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			p.manageHistory(ctx, nil)
+		}()
+		<-started
+		cancel()
+		<-done
+
+		select {
+		case event := <-events:
+			t.Errorf("unexpected archive event %#v", event)
+		default:
+		}
+		if got, want := len(p.history), 4; got != want {
+			t.Errorf("got %d, want %d", got, want)
+		}
+		if got, want := p.archivedHistory, ""; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+		if p.archiveInProgress {
+			t.Error("expected archiveInProgress to be false after cancel")
+		}
+	})
+}
+
+func TestPlayerThinkQuotaExceeded(t *testing.T) {
+	var interactCount int
+	p := newTestPlayer(func(ctx context.Context, req Request) (Response, error) {
+		interactCount++
+		return Response{}, &QuotaExceededError{RetryAfter: 10 * time.Minute}
+	})
+	var gotErr error
+	p.OnErr__0(func(err error) { gotErr = err })
+
+	outcome := p.ThinkResult__1("hello")
+
+	if got, want := interactCount, 1; got != want {
+		t.Errorf("got %d, want %d", got, want)
+	}
+	if got, want := IsQuotaExceeded(outcome.Err), true; got != want {
+		t.Errorf("got %t, want %t", got, want)
+	}
+	if got, want := QuotaRetryAfter(gotErr), 10*time.Minute; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
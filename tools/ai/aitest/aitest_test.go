@@ -0,0 +1,257 @@
+package aitest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/goplus/builder/tools/ai"
+)
+
+type MakeMove struct {
+	Row int
+	Col int
+}
+
+func newPlayer(ft *FakeTransport, handler func(cmd MakeMove) error) *ai.Player {
+	p := NewPlayer(ft)
+	p.SetInteractionPolicy(ai.InteractionPolicy{
+		MaxTurns:             3,
+		MaxTransportAttempts: 2,
+		BackoffBase:          time.Millisecond,
+		BackoffCap:           time.Millisecond,
+		RateLimitWaitTimeout: time.Second,
+	})
+	ai.XGot_Player_XGox_OnCmd(p, handler)
+	return p
+}
+
+func TestFakeTransport(t *testing.T) {
+	t.Run("QueuedResponses", func(t *testing.T) {
+		ft := &FakeTransport{}
+		ft.RespondCommand("MakeMove", map[string]any{"Row": 1, "Col": 2})
+		ft.Respond(ai.Response{Text: "Your turn!"})
+		var moves []MakeMove
+		p := newPlayer(ft, func(cmd MakeMove) error {
+			moves = append(moves, cmd)
+			return nil
+		})
+		cmds := RecordCommands(p)
+
+		outcome := p.ThinkResult__1("Make your move")
+
+		if outcome.Err != nil {
+			t.Fatalf("unexpected error: %v", outcome.Err)
+		}
+		if got, want := len(moves), 1; got != want {
+			t.Fatalf("got %d moves, want %d", got, want)
+		}
+		if got, want := moves[0], (MakeMove{Row: 1, Col: 2}); got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		cmds.ExpectCommand(t, "MakeMove", map[string]any{"Row": 1, "Col": 2})
+		cmds.ExpectCommand(t, "MakeMove", nil)
+		ExpectOutcomeCommand(t, outcome, "MakeMove", map[string]any{"Row": 1.0, "Col": 2.0})
+		if got, want := outcome.Text, "Your turn!"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+
+		reqs := ft.Requests()
+		if got, want := len(reqs), 2; got != want {
+			t.Fatalf("got %d requests, want %d", got, want)
+		}
+		if got, want := reqs[0].Content, "Make your move"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+		if got, want := reqs[1].ContinuationTurn, 1; got != want {
+			t.Errorf("got %d, want %d", got, want)
+		}
+		if got, want := len(reqs[0].CommandSpecs), 1; got != want {
+			t.Errorf("got %d command specs, want %d", got, want)
+		}
+		last, ok := ft.LastRequest()
+		if !ok {
+			t.Fatal("expected last request")
+		}
+		if got, want := last.ContinuationTurn, 1; got != want {
+			t.Errorf("got %d, want %d", got, want)
+		}
+		if got, want := ft.Pending(), 0; got != want {
+			t.Errorf("got %d, want %d", got, want)
+		}
+	})
+
+	t.Run("RespondFunc", func(t *testing.T) {
+		ft := &FakeTransport{}
+		ft.RespondCommand("MakeMove", map[string]any{"Row": 0, "Col": 0})
+		ft.RespondFunc(func(req ai.Request) (ai.Response, error) {
+			return ai.Response{Text: "fallback"}, nil
+		})
+		p := newPlayer(ft, func(cmd MakeMove) error { return nil })
+
+		outcome := p.ThinkResult__1("go")
+
+		if outcome.Err != nil {
+			t.Fatalf("unexpected error: %v", outcome.Err)
+		}
+		if got, want := outcome.Text, "fallback"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("NoResponse", func(t *testing.T) {
+		ft := &FakeTransport{}
+		p := newPlayer(ft, func(cmd MakeMove) error { return nil })
+		cmds := RecordCommands(p)
+
+		outcome := p.ThinkResult__1("go")
+
+		if !errors.Is(outcome.Err, ErrNoResponse) {
+			t.Errorf("got %v, want %v", outcome.Err, ErrNoResponse)
+		}
+		cmds.ExpectNoCommand(t)
+	})
+
+	t.Run("TooManyRequests", func(t *testing.T) {
+		ft := &FakeTransport{}
+		ft.FailTooManyRequests(time.Millisecond)
+		ft.RespondCommand("MakeMove", map[string]any{"Row": 1, "Col": 1})
+		ft.Respond(ai.Response{})
+		p := newPlayer(ft, func(cmd MakeMove) error { return nil })
+
+		outcome := p.ThinkResult__1("go")
+
+		if outcome.Err != nil {
+			t.Fatalf("unexpected error: %v", outcome.Err)
+		}
+		if got, want := len(ft.Requests()), 3; got != want {
+			t.Errorf("got %d requests, want %d", got, want)
+		}
+	})
+
+	t.Run("QuotaExceeded", func(t *testing.T) {
+		ft := &FakeTransport{}
+		ft.FailQuotaExceeded(time.Hour)
+		p := newPlayer(ft, func(cmd MakeMove) error { return nil })
+
+		outcome := p.ThinkResult__1("go")
+
+		var qeErr *ai.QuotaExceededError
+		if !errors.As(outcome.Err, &qeErr) {
+			t.Fatalf("got %v, want a QuotaExceededError", outcome.Err)
+		}
+		if got, want := qeErr.RetryAfter, time.Hour; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		var statusErr *ai.StatusError
+		if !errors.As(outcome.Err, &statusErr) {
+			t.Fatalf("got %v, want a StatusError", outcome.Err)
+		}
+		if got, want := statusErr.StatusCode, 403; got != want {
+			t.Errorf("got %d, want %d", got, want)
+		}
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		ft := &FakeTransport{}
+		ft.FailTimeout()
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		_, err := ft.Interact(ctx, ai.Request{Content: "go"})
+
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("got %v, want %v", err, context.DeadlineExceeded)
+		}
+	})
+
+	t.Run("Archive", func(t *testing.T) {
+		ft := &FakeTransport{}
+		turns := []ai.Turn{{RequestContent: "a"}, {RequestContent: "b"}}
+
+		archived, err := ft.Archive(context.Background(), turns, "")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got, want := archived.Content, "[2 archived turns]"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+
+		ft.ArchiveFunc(func(turns []ai.Turn, existingArchive string) (ai.ArchivedHistory, error) {
+			return ai.ArchivedHistory{Content: existingArchive + "+summary"}, nil
+		})
+		archived, err = ft.Archive(context.Background(), turns, "old")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got, want := archived.Content, "old+summary"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+		if got, want := len(ft.Archives()), 2; got != want {
+			t.Errorf("got %d archives, want %d", got, want)
+		}
+	})
+}
+
+func TestArgsEqual(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		a, b map[string]any
+		want bool
+	}{
+		{"Equal", map[string]any{"Row": 1}, map[string]any{"Row": 1}, true},
+		{"NumberTypes", map[string]any{"Row": 1}, map[string]any{"Row": 1.0}, true},
+		{"Nested", map[string]any{"Cells": []int{1, 2}}, map[string]any{"Cells": []any{1.0, 2.0}}, true},
+		{"DifferentValue", map[string]any{"Row": 1}, map[string]any{"Row": 2}, false},
+		{"MissingKey", map[string]any{"Row": 1}, map[string]any{}, false},
+		{"BothNil", nil, nil, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ArgsEqual(tt.a, tt.b); got != tt.want {
+				t.Errorf("got %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecutor(t *testing.T) {
+	t.Run("Synchronous", func(t *testing.T) {
+		ft := &FakeTransport{}
+		ft.RespondCommand("MakeMove", map[string]any{"Row": 1, "Col": 1})
+		ft.Respond(ai.Response{Text: "Done."})
+		p := newPlayer(ft, func(cmd MakeMove) error { return nil })
+		var events []string
+		p.OnResponse(func(resp ai.Response) {
+			events = append(events, "response "+resp.Text)
+		})
+		p.OnSequenceEnd(func(reason ai.EndReason) {
+			events = append(events, "end")
+		})
+
+		p.Think__1("go")
+
+		// Handlers ran on the calling goroutine before Think returned.
+		if got, want := len(events), 3; got != want {
+			t.Fatalf("got %d events, want %d: %q", got, want, events)
+		}
+		if got, want := events[2], "end"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("Abort", func(t *testing.T) {
+		ft := &FakeTransport{}
+		ft.FailTimeout()
+		p := newPlayer(ft, func(cmd MakeMove) error { return nil })
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		p.SetExecutor(&Executor{Context: ctx})
+
+		outcome := p.ThinkResult__1("go")
+
+		if !errors.Is(outcome.Err, ai.ErrCanceled) {
+			t.Errorf("got %v, want %v", outcome.Err, ai.ErrCanceled)
+		}
+	})
+}
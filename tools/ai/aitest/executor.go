@@ -0,0 +1,58 @@
+package aitest
+
+import (
+	"context"
+
+	"github.com/goplus/builder/tools/ai"
+)
+
+// Executor is a fake [ai.Executor] that runs everything synchronously on the
+// calling goroutine, so a [ai.Player] and its handlers run deterministically
+// in unit tests without a running spx game.
+//
+// A zero-value Executor is ready to use.
+type Executor struct {
+	// Context is passed to functions as the context of the calling script.
+	// Canceling it simulates aborting the calling script. If nil,
+	// [context.Background] is used.
+	Context context.Context
+
+	// Owner is passed to functions run via [Executor.ExecuteNative] as the
+	// owner of the calling script.
+	Owner any
+}
+
+// context returns the context of the calling script.
+func (e *Executor) context() context.Context {
+	if e.Context != nil {
+		return e.Context
+	}
+	return context.Background()
+}
+
+// ExecuteNative implements [ai.Executor].
+func (e *Executor) ExecuteNative(fn func(ctx context.Context, owner any)) {
+	fn(e.context(), e.Owner)
+}
+
+// Execute implements [ai.Executor].
+func (e *Executor) Execute(owner any, fn func(ctx context.Context, owner any)) {
+	fn(e.context(), owner)
+}
+
+// IsAbort implements [ai.Executor]. It always returns false.
+func (e *Executor) IsAbort(r any) bool {
+	return false
+}
+
+// NewPlayer creates a new [ai.Player] for unit tests. It uses transport, runs
+// synchronously via a zero-value [Executor], and has its own [ai.RateLimiter]
+// and [ai.Scheduler] with no limit, so tests don't affect each other.
+func NewPlayer(transport ai.Transport) *ai.Player {
+	p := &ai.Player{}
+	p.SetTransport(transport)
+	p.SetExecutor(&Executor{})
+	p.SetRateLimiter(&ai.RateLimiter{})
+	p.SetScheduler(&ai.Scheduler{})
+	return p
+}
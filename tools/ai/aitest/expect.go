@@ -0,0 +1,142 @@
+package aitest
+
+import (
+	"encoding/json"
+	"reflect"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/goplus/builder/tools/ai"
+)
+
+// CommandRecorder records the commands executed by a [ai.Player].
+type CommandRecorder struct {
+	mu       sync.Mutex
+	commands []ai.ExecutedCommand
+}
+
+// RecordCommands creates a new [CommandRecorder] recording the commands
+// executed by p. It registers a handler via [ai.Player.OnCommand], replacing
+// any handler registered before.
+func RecordCommands(p *ai.Player) *CommandRecorder {
+	r := &CommandRecorder{}
+	p.OnCommand(func(name string, args map[string]any, result *ai.CommandResult) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.commands = append(r.commands, ai.ExecutedCommand{
+			Name:   name,
+			Args:   args,
+			Result: result,
+		})
+	})
+	return r
+}
+
+// Commands returns the commands executed so far, in order.
+func (r *CommandRecorder) Commands() []ai.ExecutedCommand {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.commands)
+}
+
+// Reset forgets the commands recorded so far.
+func (r *CommandRecorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.commands = nil
+}
+
+// ExpectCommand reports a test failure unless a command named name was
+// executed with args. See [ArgsEqual] for how args are compared. A nil args
+// matches any arguments.
+func (r *CommandRecorder) ExpectCommand(t testing.TB, name string, args map[string]any) {
+	t.Helper()
+	expectCommand(t, r.Commands(), name, args)
+}
+
+// ExpectNoCommand reports a test failure if any command was executed.
+func (r *CommandRecorder) ExpectNoCommand(t testing.TB) {
+	t.Helper()
+	if commands := r.Commands(); len(commands) > 0 {
+		t.Errorf("got %d executed commands, want none: %s", len(commands), formatCommands(commands))
+	}
+}
+
+// ExpectOutcomeCommand reports a test failure unless outcome includes a
+// command named name executed with args. See [ArgsEqual] for how args are
+// compared. A nil args matches any arguments.
+func ExpectOutcomeCommand(t testing.TB, outcome *ai.Outcome, name string, args map[string]any) {
+	t.Helper()
+	if outcome == nil {
+		t.Errorf("got nil outcome, want command %s", name)
+		return
+	}
+	expectCommand(t, outcome.Commands, name, args)
+}
+
+// expectCommand reports a test failure unless commands include a command
+// named name with args.
+func expectCommand(t testing.TB, commands []ai.ExecutedCommand, name string, args map[string]any) {
+	t.Helper()
+	for _, cmd := range commands {
+		if cmd.Name == name && (args == nil || ArgsEqual(cmd.Args, args)) {
+			return
+		}
+	}
+	want := name
+	if args != nil {
+		want += " " + formatArgs(args)
+	}
+	t.Errorf("command %s was not executed, got: %s", want, formatCommands(commands))
+}
+
+// ArgsEqual reports whether the command arguments a and b are equal in their
+// JSON form, so, e.g., the int 1 equals the float64 1 as decoded from an AI
+// response.
+func ArgsEqual(a, b map[string]any) bool {
+	na, errA := normalizeArgs(a)
+	nb, errB := normalizeArgs(b)
+	if errA != nil || errB != nil {
+		return reflect.DeepEqual(a, b)
+	}
+	return reflect.DeepEqual(na, nb)
+}
+
+// normalizeArgs returns args as it would be decoded from JSON.
+func normalizeArgs(args map[string]any) (map[string]any, error) {
+	b, err := json.Marshal(args)
+	if err != nil {
+		return nil, err
+	}
+	var normalized map[string]any
+	if err := json.Unmarshal(b, &normalized); err != nil {
+		return nil, err
+	}
+	return normalized, nil
+}
+
+// formatCommands returns a human-readable form of commands for test failure
+// messages.
+func formatCommands(commands []ai.ExecutedCommand) string {
+	if len(commands) == 0 {
+		return "no commands"
+	}
+	var s string
+	for i, cmd := range commands {
+		if i > 0 {
+			s += ", "
+		}
+		s += cmd.Name + " " + formatArgs(cmd.Args)
+	}
+	return s
+}
+
+// formatArgs returns the JSON form of args for test failure messages.
+func formatArgs(args map[string]any) string {
+	b, err := json.Marshal(args)
+	if err != nil {
+		return "<invalid args>"
+	}
+	return string(b)
+}
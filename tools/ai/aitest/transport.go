@@ -0,0 +1,189 @@
+// Package aitest provides utilities for testing games that use AI Players,
+// without the live AI backend.
+//
+// Players created via [NewPlayer] run synchronously via a fake [Executor],
+// so [ai.Player.Think__0] and friends can be called directly from unit tests
+// without a running spx game:
+//
+//	ft := &aitest.FakeTransport{}
+//	ft.RespondCommand("MakeMove", map[string]any{"Row": 1, "Col": 2})
+//	ft.Respond(ai.Response{Text: "Your turn!"})
+//
+//	p := aitest.NewPlayer(ft)
+//	cmds := aitest.RecordCommands(p)
+//	p.Think__1("Make your move")
+//
+//	cmds.ExpectCommand(t, "MakeMove", map[string]any{"Row": 1, "Col": 2})
+package aitest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/goplus/builder/tools/ai"
+)
+
+// ErrNoResponse indicates that a [FakeTransport] received a request while no
+// response was queued and no response func was set.
+var ErrNoResponse = errors.New("aitest: no response queued")
+
+// FakeTransport is an [ai.Transport] that answers requests with programmed
+// responses and records every request it receives.
+//
+// Queued responses are used first, in order. Once they run out, the response
+// func set via [FakeTransport.RespondFunc] is used, if any. Otherwise,
+// requests fail with [ErrNoResponse].
+//
+// A zero-value FakeTransport is ready to use. It is safe for concurrent use.
+type FakeTransport struct {
+	mu          sync.Mutex
+	queue       []func(ctx context.Context) (ai.Response, error)
+	respondFunc func(req ai.Request) (ai.Response, error)
+	archiveFunc func(turns []ai.Turn, existingArchive string) (ai.ArchivedHistory, error)
+	requests    []ai.Request
+	archives    [][]ai.Turn
+}
+
+// Respond queues responses to be returned in order.
+func (f *FakeTransport) Respond(resps ...ai.Response) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, resp := range resps {
+		f.queue = append(f.queue, func(context.Context) (ai.Response, error) {
+			return resp, nil
+		})
+	}
+}
+
+// RespondCommand queues a response asking for the command with name and
+// args.
+func (f *FakeTransport) RespondCommand(name string, args map[string]any) {
+	f.Respond(ai.Response{CommandName: name, CommandArgs: args})
+}
+
+// RespondFunc sets the func used to answer requests once the queued
+// responses run out.
+func (f *FakeTransport) RespondFunc(fn func(req ai.Request) (ai.Response, error)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.respondFunc = fn
+}
+
+// Fail queues a failure with err.
+func (f *FakeTransport) Fail(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.queue = append(f.queue, func(context.Context) (ai.Response, error) {
+		return ai.Response{}, err
+	})
+}
+
+// FailTooManyRequests queues a failure as if the backend responded with HTTP
+// 429 and the Retry-After header set to retryAfter.
+func (f *FakeTransport) FailTooManyRequests(retryAfter time.Duration) {
+	f.Fail(&ai.TooManyRequestsError{
+		RetryAfter: retryAfter,
+		Err:        &ai.StatusError{StatusCode: 429, Status: "Too Many Requests"},
+	})
+}
+
+// FailQuotaExceeded queues a failure as if the backend responded with HTTP
+// 403 and the Retry-After header set to retryAfter because the quota is
+// exhausted.
+func (f *FakeTransport) FailQuotaExceeded(retryAfter time.Duration) {
+	f.Fail(&ai.QuotaExceededError{
+		RetryAfter: retryAfter,
+		Err:        &ai.StatusError{StatusCode: 403, Status: "Forbidden"},
+	})
+}
+
+// FailTimeout queues a failure as if the backend never responded. The
+// request blocks until it times out or is canceled, and fails with the error
+// of its context.
+func (f *FakeTransport) FailTimeout() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.queue = append(f.queue, func(ctx context.Context) (ai.Response, error) {
+		<-ctx.Done()
+		return ai.Response{}, ctx.Err()
+	})
+}
+
+// ArchiveFunc sets the func used to answer [ai.Transport.Archive] calls. By
+// default, archives are answered with a summary that only counts the turns.
+func (f *FakeTransport) ArchiveFunc(fn func(turns []ai.Turn, existingArchive string) (ai.ArchivedHistory, error)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.archiveFunc = fn
+}
+
+// Requests returns the requests received so far, in order.
+func (f *FakeTransport) Requests() []ai.Request {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.requests)
+}
+
+// LastRequest returns the last request received. It returns false if no
+// request was received.
+func (f *FakeTransport) LastRequest() (ai.Request, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.requests) == 0 {
+		return ai.Request{}, false
+	}
+	return f.requests[len(f.requests)-1], true
+}
+
+// Archives returns the turns of the [ai.Transport.Archive] calls received so
+// far, in order.
+func (f *FakeTransport) Archives() [][]ai.Turn {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.archives)
+}
+
+// Pending returns the number of queued responses not used yet.
+func (f *FakeTransport) Pending() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.queue)
+}
+
+// Interact implements [ai.Transport].
+func (f *FakeTransport) Interact(ctx context.Context, req ai.Request) (ai.Response, error) {
+	f.mu.Lock()
+	f.requests = append(f.requests, req)
+	var next func(ctx context.Context) (ai.Response, error)
+	if len(f.queue) > 0 {
+		next = f.queue[0]
+		f.queue = f.queue[1:]
+	}
+	respondFunc := f.respondFunc
+	f.mu.Unlock()
+
+	switch {
+	case next != nil:
+		return next(ctx)
+	case respondFunc != nil:
+		return respondFunc(req)
+	}
+	return ai.Response{}, fmt.Errorf("%w for request %q (continuation turn %d)", ErrNoResponse, req.Content, req.ContinuationTurn)
+}
+
+// Archive implements [ai.Transport].
+func (f *FakeTransport) Archive(ctx context.Context, turns []ai.Turn, existingArchive string) (ai.ArchivedHistory, error) {
+	f.mu.Lock()
+	f.archives = append(f.archives, turns)
+	archiveFunc := f.archiveFunc
+	f.mu.Unlock()
+
+	if archiveFunc != nil {
+		return archiveFunc(turns, existingArchive)
+	}
+	return ai.ArchivedHistory{Content: fmt.Sprintf("%s[%d archived turns]", existingArchive, len(turns))}, nil
+}
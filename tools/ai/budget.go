@@ -0,0 +1,159 @@
+package ai
+
+import (
+	"encoding/json"
+	"log"
+	"slices"
+	"sync"
+)
+
+// Tokenizer estimates the number of model tokens in a piece of text. It is
+// used to keep requests within [InteractionPolicy.RequestTokenBudget].
+type Tokenizer interface {
+	// CountTokens returns the estimated number of tokens in text.
+	CountTokens(text string) int
+}
+
+// TokenizerFunc is an adapter to allow the use of ordinary functions as
+// [Tokenizer].
+type TokenizerFunc func(text string) int
+
+// CountTokens implements [Tokenizer].
+func (f TokenizerFunc) CountTokens(text string) int {
+	return f(text)
+}
+
+// approxTokenizer is the [Tokenizer] implementation that estimates one token
+// per 4 bytes, a common approximation for English text and JSON.
+type approxTokenizer struct{}
+
+// CountTokens implements [Tokenizer].
+func (approxTokenizer) CountTokens(text string) int {
+	return (len(text) + 3) / 4
+}
+
+var (
+	// defaultTokenizer holds the default instance of [Tokenizer].
+	defaultTokenizer   Tokenizer = approxTokenizer{}
+	defaultTokenizerMu sync.RWMutex
+
+	// defaultTokenizerGen is bumped whenever the default [Tokenizer]
+	// changes, so players recount their cached history token counts.
+	defaultTokenizerGen uint64
+)
+
+// DefaultTokenizer returns the default [Tokenizer] instance.
+func DefaultTokenizer() Tokenizer {
+	defaultTokenizerMu.RLock()
+	defer defaultTokenizerMu.RUnlock()
+	return defaultTokenizer
+}
+
+// SetDefaultTokenizer sets the default instance of [Tokenizer] used to
+// estimate request sizes. It resets to the built-in approximation, which
+// estimates one token per 4 bytes, if nil is provided.
+func SetDefaultTokenizer(t Tokenizer) {
+	defaultTokenizerMu.Lock()
+	defer defaultTokenizerMu.Unlock()
+	if t == nil {
+		t = approxTokenizer{}
+	}
+	defaultTokenizer = t
+	defaultTokenizerGen++
+}
+
+// SetTokenizer sets a custom [Tokenizer] for the player. It resets to
+// [DefaultTokenizer] if nil is provided.
+func (p *Player) SetTokenizer(t Tokenizer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.customTokenizer = t
+	p.historyTokens = nil
+}
+
+// historyTokenCounts returns the [Tokenizer] instance used to estimate
+// request sizes, together with the estimated token counts of the turns in
+// p.history. Turns never change once appended, so each turn is only counted
+// once per tokenizer. The caller must hold p.mu for writing.
+func (p *Player) historyTokenCounts() (Tokenizer, []int) {
+	tokenizer, gen := p.customTokenizer, p.historyTokensGen
+	if tokenizer == nil {
+		defaultTokenizerMu.RLock()
+		tokenizer, gen = defaultTokenizer, defaultTokenizerGen
+		defaultTokenizerMu.RUnlock()
+	}
+	if gen != p.historyTokensGen || len(p.historyTokens) > len(p.history) {
+		p.historyTokens = nil
+		p.historyTokensGen = gen
+	}
+	for _, turn := range p.history[len(p.historyTokens):] {
+		p.historyTokens = append(p.historyTokens, countJSONTokens(tokenizer, turn))
+	}
+	return tokenizer, slices.Clone(p.historyTokens)
+}
+
+// countJSONTokens returns the estimated number of tokens in the JSON encoding
+// of v.
+func countJSONTokens(tokenizer Tokenizer, v any) int {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return 0
+	}
+	return tokenizer.CountTokens(string(b))
+}
+
+// fitRequestToBudget trims the oldest turns from req.History so that the
+// estimated size of req fits in budget tokens. Turns are only dropped as
+// whole interaction sequences starting with an IsInitial turn, and the most
+// recent sequence is always kept, so the request may still exceed the budget.
+//
+// turnTokens holds the estimated token counts of the turns in req.History.
+// They are counted here if turnTokens is nil.
+//
+// The dropped turns stay in the player's history until they are archived.
+// Until then, a local summary of them made by [SummarizeLocally] is added to
+// req.ArchivedHistory, so the AI does not lose their context silently.
+func fitRequestToBudget(req *Request, tokenizer Tokenizer, budget int, turnTokens []int) {
+	history := req.History
+	if turnTokens == nil {
+		turnTokens = make([]int, len(history))
+		for i, turn := range history {
+			turnTokens[i] = countJSONTokens(tokenizer, turn)
+		}
+	}
+	historyTokens := 0
+	for _, n := range turnTokens {
+		historyTokens += n
+	}
+
+	archivedHistory := req.ArchivedHistory
+	req.History = nil
+	fixedTokens := countJSONTokens(tokenizer, req)
+
+	// Drop whole sequences from the front while over budget. A sequence ends
+	// right before the next IsInitial turn.
+	start := 0
+	for fixedTokens+historyTokens > budget {
+		next := start + 1
+		for next < len(history) && !history[next].IsInitial {
+			next++
+		}
+		if next >= len(history) {
+			// Never drop the most recent sequence.
+			break
+		}
+		for _, n := range turnTokens[start:next] {
+			historyTokens -= n
+		}
+		start = next
+
+		req.ArchivedHistory = SummarizeLocally(history[:start], archivedHistory)
+		fixedTokens = countJSONTokens(tokenizer, req)
+	}
+	req.History = history[start:]
+
+	if start > 0 {
+		log.Printf("AI request exceeds token budget %d, dropped %d of %d history turns, now about %d tokens",
+			budget, start, len(history), fixedTokens+historyTokens)
+	}
+}
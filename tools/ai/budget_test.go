@@ -0,0 +1,222 @@
+package ai
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestApproxTokenizer(t *testing.T) {
+	for _, tt := range []struct {
+		text string
+		want int
+	}{
+		{text: "", want: 0},
+		{text: "a", want: 1},
+		{text: "abcd", want: 1},
+		{text: "abcde", want: 2},
+	} {
+		if got, want := (approxTokenizer{}).CountTokens(tt.text), tt.want; got != want {
+			t.Errorf("CountTokens(%q): got %d, want %d", tt.text, got, want)
+		}
+	}
+}
+
+func TestFitRequestToBudget(t *testing.T) {
+	// Every JSON value counts as 10 tokens: the request without history, and
+	// each history turn.
+	tokenizer := TokenizerFunc(func(text string) int { return 10 })
+	makeHistory := func(initials ...bool) []Turn {
+		history := make([]Turn, len(initials))
+		for i, isInitial := range initials {
+			history[i] = Turn{RequestContent: string(rune('a' + i)), IsInitial: isInitial}
+		}
+		return history
+	}
+
+	for _, tt := range []struct {
+		name         string
+		history      []Turn
+		budget       int
+		wantContent  []string
+		wantArchived string
+	}{
+		{
+			name:         "WithinBudget",
+			history:      makeHistory(true, false, true),
+			budget:       40,
+			wantContent:  []string{"a", "b", "c"},
+			wantArchived: "earlier",
+		},
+		{
+			name:         "DropOldestSequence",
+			history:      makeHistory(true, false, true, true, false),
+			budget:       45,
+			wantContent:  []string{"c", "d", "e"},
+			wantArchived: "earlier\nAsked: a",
+		},
+		{
+			name:         "DropMultipleSequences",
+			history:      makeHistory(true, false, true, true, false),
+			budget:       30,
+			wantContent:  []string{"d", "e"},
+			wantArchived: "earlier\nAsked: a\nAsked: c",
+		},
+		{
+			name:         "KeepMostRecentSequence",
+			history:      makeHistory(true, true, false, false),
+			budget:       15,
+			wantContent:  []string{"b", "c", "d"},
+			wantArchived: "earlier\nAsked: a",
+		},
+		{
+			name:         "LeadingPartialSequence",
+			history:      makeHistory(false, false, true),
+			budget:       25,
+			wantContent:  []string{"c"},
+			wantArchived: "earlier",
+		},
+		{
+			name:         "EmptyHistory",
+			budget:       5,
+			history:      nil,
+			wantArchived: "earlier",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			req := Request{Content: "now", History: tt.history, ArchivedHistory: "earlier"}
+			fitRequestToBudget(&req, tokenizer, tt.budget, nil)
+
+			var gotContent []string
+			for _, turn := range req.History {
+				gotContent = append(gotContent, turn.RequestContent)
+			}
+			if got, want := gotContent, tt.wantContent; !reflect.DeepEqual(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+			if got, want := req.ArchivedHistory, tt.wantArchived; got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+			if got, want := req.Content, "now"; got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestPlayerSetTokenizer(t *testing.T) {
+	originalTokenizer := DefaultTokenizer()
+	t.Cleanup(func() { SetDefaultTokenizer(originalTokenizer) })
+
+	p := &Player{}
+	tokenizer := func() Tokenizer {
+		tokenizer, _ := p.historyTokenCounts()
+		return tokenizer
+	}
+	if got, want := tokenizer(), Tokenizer(approxTokenizer{}); got != want {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+
+	defaultTokenizer := &struct{ TokenizerFunc }{TokenizerFunc(func(text string) int { return 1 })}
+	SetDefaultTokenizer(defaultTokenizer)
+	if got, want := tokenizer(), Tokenizer(defaultTokenizer); got != want {
+		t.Errorf("got %p, want %p", got, want)
+	}
+
+	customTokenizer := &struct{ TokenizerFunc }{TokenizerFunc(func(text string) int { return 2 })}
+	p.SetTokenizer(customTokenizer)
+	if got, want := tokenizer(), Tokenizer(customTokenizer); got != want {
+		t.Errorf("got %p, want %p", got, want)
+	}
+
+	p.SetTokenizer(nil)
+	if got, want := tokenizer(), Tokenizer(defaultTokenizer); got != want {
+		t.Errorf("got %p, want %p", got, want)
+	}
+
+	SetDefaultTokenizer(nil)
+	if got, want := tokenizer(), Tokenizer(approxTokenizer{}); got != want {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+}
+
+func TestPlayerHistoryTokenCounts(t *testing.T) {
+	originalTokenizer := DefaultTokenizer()
+	t.Cleanup(func() { SetDefaultTokenizer(originalTokenizer) })
+
+	var calls int
+	countingTokenizer := func(n int) Tokenizer {
+		return TokenizerFunc(func(text string) int {
+			calls++
+			return n
+		})
+	}
+
+	p := &Player{history: []Turn{{RequestContent: "a"}, {RequestContent: "b"}}}
+	p.SetTokenizer(countingTokenizer(1))
+	_, counts := p.historyTokenCounts()
+	if got, want := counts, []int{1, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// Only new turns are counted.
+	calls = 0
+	p.history = append(p.history, Turn{RequestContent: "c"})
+	_, counts = p.historyTokenCounts()
+	if got, want := counts, []int{1, 1, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := calls, 1; got != want {
+		t.Errorf("got %d, want %d", got, want)
+	}
+
+	// Changing the tokenizer recounts all turns.
+	p.SetTokenizer(countingTokenizer(2))
+	_, counts = p.historyTokenCounts()
+	if got, want := counts, []int{2, 2, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	p.SetTokenizer(nil)
+	SetDefaultTokenizer(countingTokenizer(3))
+	_, counts = p.historyTokenCounts()
+	if got, want := counts, []int{3, 3, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// Archiving shifts the cached counts along with the history.
+	calls = 0
+	p.applyArchive("archived", 2)
+	_, counts = p.historyTokenCounts()
+	if got, want := counts, []int{3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := calls, 0; got != want {
+		t.Errorf("got %d, want %d", got, want)
+	}
+}
+
+func TestPlayerThinkTokenBudget(t *testing.T) {
+	var gotHistoryLens []int
+	p := &Player{}
+	p.SetTransport(&mockTransport{
+		InteractFunc: func(ctx context.Context, req Request) (Response, error) {
+			gotHistoryLens = append(gotHistoryLens, len(req.History))
+			if req.ContinuationTurn == 0 {
+				return Response{CommandName: "NoopCmd"}, nil
+			}
+			return Response{}, nil
+		},
+	})
+	p.SetTokenizer(TokenizerFunc(func(text string) int { return 10 }))
+	p.SetInteractionPolicy(InteractionPolicy{RequestTokenBudget: 35})
+	XGot_Player_XGox_OnCmd(p, func(cmd NoopCmd) error { return nil })
+
+	p.Think__1("first")
+	p.Think__1("second")
+
+	// The second sequence only sees its own turns once the first sequence
+	// no longer fits in the budget.
+	if got, want := gotHistoryLens, []int{0, 1, 2, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -5,8 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
-
-	"github.com/goplus/spx/v2/pkg/spx"
+	"strconv"
+	"strings"
 )
 
 // CommandSpec describes an available AI command, derived from a command registration.
@@ -21,9 +21,29 @@ type CommandSpec struct {
 	Parameters []CommandParamSpec `json:"parameters,omitempty"`
 }
 
+// ParametersSchema returns a JSON Schema describing the arguments object of
+// the command, suitable for tool definitions of model APIs.
+func (s CommandSpec) ParametersSchema() map[string]any {
+	properties := make(map[string]any, len(s.Parameters))
+	required := []any{}
+	for _, param := range s.Parameters {
+		properties[param.Name] = param.Schema
+		if param.Required {
+			required = append(required, param.Name)
+		}
+	}
+	return map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}
+
 // CommandParamSpec describes a parameter for an AI command.
 type CommandParamSpec struct {
-	// Name is the parameter name (e.g., struct field name).
+	// Name is the parameter name (e.g., struct field name, or the value of
+	// its "name" tag).
 	Name string `json:"name"`
 
 	// Type is the Go type name of the parameter (e.g., "string", "int", "[]float64").
@@ -31,6 +51,30 @@ type CommandParamSpec struct {
 
 	// Description explains the purpose of the parameter.
 	Description string `json:"description,omitempty"`
+
+	// Required indicates whether the AI is expected to always provide a value
+	// for the parameter.
+	Required bool `json:"required,omitempty"`
+
+	// Enum lists the allowed values of the parameter (or of its elements for
+	// slice and array parameters), if restricted by the "enum" tag.
+	Enum []any `json:"enum,omitempty"`
+
+	// Minimum is the smallest allowed value of a numeric parameter, if
+	// restricted by the "min" tag.
+	Minimum *float64 `json:"minimum,omitempty"`
+
+	// Maximum is the largest allowed value of a numeric parameter, if
+	// restricted by the "max" tag.
+	Maximum *float64 `json:"maximum,omitempty"`
+
+	// Default is the value used when the AI omits the parameter, if declared
+	// by the "default" tag.
+	Default any `json:"default,omitempty"`
+
+	// Schema is a JSON Schema fragment describing the JSON representation of
+	// the parameter, including its description.
+	Schema map[string]any `json:"schema,omitempty"`
 }
 
 // CommandResult represents the outcome of executing an AI-requested command.
@@ -53,24 +97,52 @@ type commandInfo struct {
 	typ     reflect.Type
 	handler any // func(cmd T) error
 	spec    CommandSpec
+	tags    structTags
+}
+
+// newCommandInfo creates a [commandInfo] for a command struct type and its
+// handler. Invalid struct tags of the command are reported as an error. It
+// assumes cmdType is already validated to be a named struct type.
+func newCommandInfo(cmdType reflect.Type, handler any) (commandInfo, error) {
+	st := structTags{}
+	if err := st.parse(cmdType); err != nil {
+		return commandInfo{}, err
+	}
+	return commandInfo{
+		typ:     cmdType,
+		handler: handler,
+		spec:    extractCommandSpec(cmdType, st),
+		tags:    st,
+	}, nil
 }
 
 // extractCommandSpec uses reflection to build a [CommandSpec] from a command
-// struct type. It assumes cmdType is already validated to be a named struct type.
-func extractCommandSpec(cmdType reflect.Type) CommandSpec {
+// struct type, using the parsed struct tags in st. It assumes cmdType is
+// already validated to be a named struct type.
+func extractCommandSpec(cmdType reflect.Type, st structTags) CommandSpec {
 	spec := CommandSpec{
 		Name:       cmdType.Name(),
 		Parameters: []CommandParamSpec{},
 	}
 
 	// Extract parameters from exported struct fields.
+	fieldTags := st.of(cmdType)
 	for i := range cmdType.NumField() {
 		field := cmdType.Field(i)
 		if field.IsExported() {
+			tags := fieldTags[i]
 			paramSpec := CommandParamSpec{
-				Name:        field.Name,
+				Name:        tags.name,
 				Type:        field.Type.String(),
-				Description: field.Tag.Get("desc"),
+				Description: tags.description,
+				Required:    tags.isRequired(),
+				Enum:        tags.enum,
+				Minimum:     tags.minimum,
+				Maximum:     tags.maximum,
+				Schema:      (&jsonSchemaBuilder{tags: st, visiting: []reflect.Type{cmdType}}).buildField(field, tags),
+			}
+			if tags.hasDefault() {
+				paramSpec.Default = tags.defaultValue(field.Type)
 			}
 			spec.Parameters = append(spec.Parameters, paramSpec)
 		}
@@ -89,16 +161,22 @@ func extractCommandSpec(cmdType reflect.Type) CommandSpec {
 
 // callCommandHandler handles the overall logic for executing a command
 // handler. It creates the command struct, populates its fields, calls the
-// handler, and processes the result.
-func callCommandHandler(owner any, info commandInfo, args map[string]any) (*CommandResult, error) {
+// handler via executor, and processes the result.
+func callCommandHandler(executor Executor, owner any, info commandInfo, args map[string]any) (*CommandResult, error) {
 	// Create a new zero value of the command struct type (T).
 	cmdType := info.typ
 	cmdPtrVal := reflect.New(cmdType)
 	cmdVal := cmdPtrVal.Elem()
 
-	// Populate struct fields from args.
-	if err := populateCommandFields(cmdVal, args); err != nil {
-		return nil, fmt.Errorf("failed to populate command fields for %s: %w", info.spec.Name, err)
+	// Populate struct fields from args. Errors of specific arguments, like
+	// constraint violations and type mismatches, are reported back to the AI
+	// so it can correct its arguments.
+	if err := populateCommandFields(cmdVal, args, info.tags); err != nil {
+		var pathErr *argPathError
+		if errors.As(err, &pathErr) {
+			return &CommandResult{ErrorMessage: "invalid arguments: " + err.Error()}, nil
+		}
+		return nil, fmt.Errorf("%w for %s: %w", ErrInvalidCommandArgs, info.spec.Name, err)
 	}
 
 	// Call the actual handler function.
@@ -106,12 +184,12 @@ func callCommandHandler(owner any, info commandInfo, args map[string]any) (*Comm
 		results        []reflect.Value
 		handlerCallErr error
 	)
-	spx.Execute(owner, func(ctx context.Context, owner any) {
+	executor.Execute(owner, func(ctx context.Context, owner any) {
 		func(handlerVal reflect.Value) {
 			defer func() {
 				if r := recover(); r != nil {
-					if !spx.IsAbortThreadError(r) {
-						handlerCallErr = fmt.Errorf("panic in command handler: %v", r)
+					if !executor.IsAbort(r) {
+						handlerCallErr = &CommandHandlerPanicError{CommandName: info.spec.Name, Value: r}
 					}
 				}
 			}()
@@ -119,7 +197,7 @@ func callCommandHandler(owner any, info commandInfo, args map[string]any) (*Comm
 		}(reflect.ValueOf(info.handler))
 	})
 	if handlerCallErr != nil {
-		return nil, fmt.Errorf("failed to call command handler for %s: %w", info.spec.Name, handlerCallErr)
+		return nil, handlerCallErr
 	}
 
 	// Process handler results.
@@ -149,51 +227,110 @@ func callCommandHandler(owner any, info commandInfo, args map[string]any) (*Comm
 }
 
 // populateCommandFields iterates through command struct fields and populates
-// them from args.
-func populateCommandFields(cmdVal reflect.Value, args map[string]any) error {
-	if args == nil {
-		return nil
-	}
-	cmdType := cmdVal.Type()
-	for i := range cmdType.NumField() {
-		fieldStruct := cmdType.Field(i)
+// them from args, using the parsed struct tags in st.
+func populateCommandFields(cmdVal reflect.Value, args map[string]any, st structTags) error {
+	return populateStructFields(cmdVal, reflect.ValueOf(args), st)
+}
+
+// populateStructFields iterates through the exported fields of structVal and
+// populates them from the entries of argMapVal, which must be a map with
+// string keys. Fields are looked up by their parameter name, and the
+// constraints declared in their struct tags are enforced.
+func populateStructFields(structVal, argMapVal reflect.Value, st structTags) error {
+	keyType := argMapVal.Type().Key()
+	structType := structVal.Type()
+	fieldTags := st.of(structType)
+	for i := range structType.NumField() {
+		fieldStruct := structType.Field(i)
 		if !fieldStruct.IsExported() {
 			continue
 		}
 
-		fieldName := fieldStruct.Name
-		fieldVal := cmdVal.FieldByName(fieldName)
-		if !fieldVal.IsValid() || !fieldVal.CanSet() {
+		fieldVal := structVal.Field(i)
+		if !fieldVal.CanSet() {
 			// This should never happen, but just in case.
 			continue
 		}
 
-		argValRaw, ok := args[fieldName]
-		if !ok {
+		tags := fieldTags[i]
+		fieldName := tags.name
+		var argVal reflect.Value
+		if argMapVal.IsValid() && !argMapVal.IsNil() {
+			argVal = argMapVal.MapIndex(reflect.ValueOf(fieldName).Convert(keyType))
+		}
+		present := argVal.IsValid()
+		if argVal.Kind() == reflect.Interface {
+			argVal = argVal.Elem()
+		}
+		if !argVal.IsValid() && (tags.required != nil || tags.hasDefault()) {
+			// An explicit null is treated as absent for fields with
+			// declared presence semantics.
+			present = false
+		}
+
+		if !present {
+			if tags.hasDefault() {
+				defaultVal := reflect.ValueOf(tags.defaultValue(fieldStruct.Type))
+				if err := setField(fieldName, fieldVal, defaultVal, st); err != nil {
+					return wrapArgPath(err, fieldName)
+				}
+			} else if tags.isRequired() {
+				return wrapArgPath(&constraintError{msg: "missing required value"}, fieldName)
+			}
 			continue
 		}
-		argReflectVal := reflect.ValueOf(argValRaw)
 
-		if err := setField(fieldName, fieldVal, argReflectVal); err != nil {
-			return fmt.Errorf("field %s: %w", fieldName, err)
+		if err := setField(fieldName, fieldVal, argVal, st); err != nil {
+			return wrapArgPath(err, fieldName)
+		}
+		if err := tags.validate(fieldVal); err != nil {
+			return wrapArgPath(err, fieldName)
 		}
 	}
 	return nil
 }
 
+// argPathError is an error that occurred while decoding the value at a
+// specific path within the command arguments, such as "Moves[2].Target.X".
+type argPathError struct {
+	path string
+	err  error
+}
+
+// Error implements [error].
+func (e *argPathError) Error() string {
+	return fmt.Sprintf("field %s: %v", e.path, e.err)
+}
+
+// Unwrap returns the underlying error.
+func (e *argPathError) Unwrap() error {
+	return e.err
+}
+
+// wrapArgPath wraps err so that its path starts with segment, which is either
+// a field name or an index expression like "[2]".
+func wrapArgPath(err error, segment string) error {
+	var pathErr *argPathError
+	if !errors.As(err, &pathErr) {
+		return &argPathError{path: segment, err: err}
+	}
+	if strings.HasPrefix(pathErr.path, "[") {
+		return &argPathError{path: segment + pathErr.path, err: pathErr.err}
+	}
+	return &argPathError{path: segment + "." + pathErr.path, err: pathErr.err}
+}
+
 // setField handles setting a single field value with type checking and
 // conversion. It takes the target field value, the argument value (as
-// [reflect.Value]), and the field name.
-func setField(fieldName string, fieldVal, argVal reflect.Value) error {
+// [reflect.Value]), the field name, and the parsed struct tags of nested
+// structs.
+func setField(fieldName string, fieldVal, argVal reflect.Value, st structTags) error {
 	// Handle invalid arg value (e.g., from reflect.ValueOf(nil)).
 	if !argVal.IsValid() {
-		switch fieldVal.Kind() {
-		case reflect.Interface, reflect.Pointer, reflect.Map, reflect.Slice, reflect.Chan, reflect.Func:
-			if fieldVal.CanSet() {
-				nilValue := reflect.Zero(fieldVal.Type())
-				fieldVal.Set(nilValue)
-				return nil
-			}
+		if isNillableKind(fieldVal.Kind()) && fieldVal.CanSet() {
+			nilValue := reflect.Zero(fieldVal.Type())
+			fieldVal.Set(nilValue)
+			return nil
 		}
 		return fmt.Errorf("cannot set field %s to nil", fieldName)
 	}
@@ -212,9 +349,35 @@ func setField(fieldName string, fieldVal, argVal reflect.Value) error {
 		return setIntFieldFromFloat(fieldVal, argVal)
 	}
 
-	// Slice assignment.
-	if fieldType.Kind() == reflect.Slice && argType.Kind() == reflect.Slice {
-		return convertAndSetSlice(fieldVal, argVal, fieldName)
+	switch fieldType.Kind() {
+	case reflect.Pointer:
+		// Decode into a newly allocated value.
+		elemPtrVal := reflect.New(fieldType.Elem())
+		if err := setField(fieldName, elemPtrVal.Elem(), argVal, st); err != nil {
+			return err
+		}
+		fieldVal.Set(elemPtrVal)
+		return nil
+	case reflect.Struct:
+		// Struct from a JSON object.
+		if argType.Kind() == reflect.Map && argType.Key().Kind() == reflect.String {
+			return populateStructFields(fieldVal, argVal, st)
+		}
+	case reflect.Map:
+		// Map from a JSON object.
+		if argType.Kind() == reflect.Map {
+			return convertAndSetMap(fieldVal, argVal, st)
+		}
+	case reflect.Array:
+		// Array from a JSON array.
+		if argType.Kind() == reflect.Slice || argType.Kind() == reflect.Array {
+			return convertAndSetArray(fieldVal, argVal, fieldName, st)
+		}
+	case reflect.Slice:
+		// Slice assignment.
+		if argType.Kind() == reflect.Slice {
+			return convertAndSetSlice(fieldVal, argVal, fieldName, st)
+		}
 	}
 
 	// General conversion.
@@ -257,23 +420,25 @@ func setIntFieldFromFloat(fieldVal, argVal reflect.Value) error {
 // convertAndSetSlice handles converting and setting slice types. It iterates
 // through the input slice (argSliceVal, likely []any) and converts each
 // element to the target slice's element type (elemType).
-func convertAndSetSlice(fieldVal, argSliceVal reflect.Value, fieldName string) error {
+func convertAndSetSlice(fieldVal, argSliceVal reflect.Value, fieldName string, st structTags) error {
 	elemType := fieldVal.Type().Elem()
 	outSlice := reflect.MakeSlice(fieldVal.Type(), 0, argSliceVal.Len())
 	for i := range argSliceVal.Len() {
 		elemVal := argSliceVal.Index(i)
+		if elemVal.Kind() == reflect.Interface {
+			elemVal = elemVal.Elem()
+		}
 		if !elemVal.IsValid() {
-			return fmt.Errorf("invalid nil element at index %d", i)
-		} else if elemVal.Kind() == reflect.Interface {
-			if elemVal.IsNil() {
+			if !isNillableKind(elemType.Kind()) {
 				return fmt.Errorf("nil element at index %d", i)
 			}
-			elemVal = elemVal.Elem()
+			outSlice = reflect.Append(outSlice, reflect.Zero(elemType))
+			continue
 		}
 
-		convertedElem, err := convertSliceElement(elemVal, elemType)
+		convertedElem, err := convertSliceElement(elemVal, elemType, st)
 		if err != nil {
-			return fmt.Errorf("element at index %d: %w", i, err)
+			return wrapArgPath(err, fmt.Sprintf("[%d]", i))
 		}
 		outSlice = reflect.Append(outSlice, convertedElem)
 	}
@@ -281,8 +446,94 @@ func convertAndSetSlice(fieldVal, argSliceVal reflect.Value, fieldName string) e
 	return nil
 }
 
-// convertSliceElement handles conversion for a single slice element.
-func convertSliceElement(elemVal reflect.Value, elemType reflect.Type) (reflect.Value, error) {
+// convertAndSetArray handles converting and setting array types. The input
+// must have exactly as many elements as the target array.
+func convertAndSetArray(fieldVal, argSliceVal reflect.Value, fieldName string, st structTags) error {
+	if got, want := argSliceVal.Len(), fieldVal.Len(); got != want {
+		return fmt.Errorf("length mismatch: got %d, want %d", got, want)
+	}
+	sliceVal := reflect.New(reflect.SliceOf(fieldVal.Type().Elem())).Elem()
+	if err := convertAndSetSlice(sliceVal, argSliceVal, fieldName, st); err != nil {
+		return err
+	}
+	reflect.Copy(fieldVal, sliceVal)
+	return nil
+}
+
+// convertAndSetMap handles converting and setting map types. Keys are
+// converted from their JSON string form, and values are converted to the
+// target map's element type.
+func convertAndSetMap(fieldVal, argMapVal reflect.Value, st structTags) error {
+	keyType := fieldVal.Type().Key()
+	elemType := fieldVal.Type().Elem()
+	outMap := reflect.MakeMapWithSize(fieldVal.Type(), argMapVal.Len())
+	iter := argMapVal.MapRange()
+	for iter.Next() {
+		keyVal := iter.Key()
+		if keyVal.Kind() == reflect.Interface {
+			keyVal = keyVal.Elem()
+		}
+		segment := fmt.Sprintf("[%v]", keyVal)
+		if keyVal.Kind() == reflect.String {
+			segment = fmt.Sprintf("[%q]", keyVal.String())
+		}
+		convertedKey, err := convertMapKey(keyVal, keyType, st)
+		if err != nil {
+			return wrapArgPath(err, segment)
+		}
+
+		elemVal := iter.Value()
+		if elemVal.Kind() == reflect.Interface {
+			elemVal = elemVal.Elem()
+		}
+		if !elemVal.IsValid() {
+			if !isNillableKind(elemType.Kind()) {
+				return wrapArgPath(errors.New("nil value"), segment)
+			}
+			outMap.SetMapIndex(convertedKey, reflect.Zero(elemType))
+			continue
+		}
+
+		convertedElem, err := convertSliceElement(elemVal, elemType, st)
+		if err != nil {
+			return wrapArgPath(err, segment)
+		}
+		outMap.SetMapIndex(convertedKey, convertedElem)
+	}
+	fieldVal.Set(outMap)
+	return nil
+}
+
+// convertMapKey converts a JSON object key to keyType. Integer key types are
+// parsed from their decimal string form, as encoded by [encoding/json].
+func convertMapKey(keyVal reflect.Value, keyType reflect.Type, st structTags) (reflect.Value, error) {
+	if !keyVal.IsValid() {
+		return reflect.Value{}, errors.New("invalid nil key")
+	}
+	if keyVal.Kind() == reflect.String && isIntKind(keyType.Kind()) {
+		outKey := reflect.New(keyType).Elem()
+		switch keyType.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n, err := strconv.ParseInt(keyVal.String(), 10, keyType.Bits())
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("invalid integer key: %w", err)
+			}
+			outKey.SetInt(n)
+		default:
+			n, err := strconv.ParseUint(keyVal.String(), 10, keyType.Bits())
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("invalid unsigned integer key: %w", err)
+			}
+			outKey.SetUint(n)
+		}
+		return outKey, nil
+	}
+	return convertSliceElement(keyVal, keyType, st)
+}
+
+// convertSliceElement handles conversion for a single slice element. It is
+// also used for array elements and map keys and values.
+func convertSliceElement(elemVal reflect.Value, elemType reflect.Type, st structTags) (reflect.Value, error) {
 	elemConcreteType := elemVal.Type()
 
 	// Direct assignment.
@@ -295,6 +546,16 @@ func convertSliceElement(elemVal reflect.Value, elemType reflect.Type) (reflect.
 		return convertFloatToSliceIntElement(elemVal, elemType)
 	}
 
+	// Composite types are decoded recursively.
+	switch elemType.Kind() {
+	case reflect.Pointer, reflect.Struct, reflect.Map, reflect.Array, reflect.Slice:
+		outElem := reflect.New(elemType).Elem()
+		if err := setField("element", outElem, elemVal, st); err != nil {
+			return reflect.Value{}, err
+		}
+		return outElem, nil
+	}
+
 	// General conversion.
 	if elemConcreteType.ConvertibleTo(elemType) {
 		return elemVal.Convert(elemType), nil
@@ -327,6 +588,15 @@ func convertFloatToSliceIntElement(elemVal reflect.Value, elemType reflect.Type)
 	return reflect.Value{}, fmt.Errorf("unexpected integer kind %s for slice element", elemType.Kind())
 }
 
+// isNillableKind reports whether values of the kind can be nil.
+func isNillableKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Interface, reflect.Pointer, reflect.Map, reflect.Slice, reflect.Chan, reflect.Func:
+		return true
+	}
+	return false
+}
+
 // isIntKind reports whether the kind is one of the integer types.
 func isIntKind(kind reflect.Kind) bool {
 	switch kind {
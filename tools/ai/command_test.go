@@ -48,8 +48,8 @@ func TestExtractCommandSpec(t *testing.T) {
 				Name:        "SimpleCmd",
 				Description: "Command SimpleCmd",
 				Parameters: []CommandParamSpec{
-					{Name: "Param1", Type: "string", Description: "First parameter"},
-					{Name: "Param2", Type: "int", Description: ""},
+					{Name: "Param1", Type: "string", Description: "First parameter", Schema: map[string]any{"type": "string", "description": "First parameter"}},
+					{Name: "Param2", Type: "int", Description: "", Schema: map[string]any{"type": "integer"}},
 				},
 			},
 		},
@@ -60,7 +60,7 @@ func TestExtractCommandSpec(t *testing.T) {
 				Name:        "CmdWithDescMethod",
 				Description: "Command with value receiver Desc",
 				Parameters: []CommandParamSpec{
-					{Name: "Value", Type: "float64", Description: "Some value"},
+					{Name: "Value", Type: "float64", Description: "Some value", Schema: map[string]any{"type": "number", "description": "Some value"}},
 				},
 			},
 		},
@@ -71,7 +71,7 @@ func TestExtractCommandSpec(t *testing.T) {
 				Name:        "CmdWithPtrDescMethod",
 				Description: "Command with pointer receiver Desc",
 				Parameters: []CommandParamSpec{
-					{Name: "Flag", Type: "bool", Description: ""},
+					{Name: "Flag", Type: "bool", Description: "", Schema: map[string]any{"type": "boolean"}},
 				},
 			},
 		},
@@ -82,14 +82,14 @@ func TestExtractCommandSpec(t *testing.T) {
 				Name:        "CmdWithSlice",
 				Description: "Command CmdWithSlice",
 				Parameters: []CommandParamSpec{
-					{Name: "Items", Type: "[]string", Description: "List of items"},
-					{Name: "Nums", Type: "[]int", Description: ""},
+					{Name: "Items", Type: "[]string", Description: "List of items", Schema: map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "List of items"}},
+					{Name: "Nums", Type: "[]int", Description: "", Schema: map[string]any{"type": "array", "items": map[string]any{"type": "integer"}}},
 				},
 			},
 		},
 	} {
 		t.Run(tt.name, func(t *testing.T) {
-			if got, want := extractCommandSpec(tt.typ), tt.wantSpec; !reflect.DeepEqual(got, want) {
+			if got, want := extractCommandSpec(tt.typ, nil), tt.wantSpec; !reflect.DeepEqual(got, want) {
 				t.Errorf("got %#v, want %#v", got, want)
 			}
 		})
@@ -111,7 +111,7 @@ func TestCallCommandHandler(t *testing.T) {
 			}
 			return nil
 		},
-		spec: extractCommandSpec(reflect.TypeOf(MoveCmd{})),
+		spec: extractCommandSpec(reflect.TypeOf(MoveCmd{}), nil),
 	}
 
 	type SliceCmd struct {
@@ -127,7 +127,7 @@ func TestCallCommandHandler(t *testing.T) {
 			}
 			return nil
 		},
-		spec: extractCommandSpec(reflect.TypeOf(SliceCmd{})),
+		spec: extractCommandSpec(reflect.TypeOf(SliceCmd{}), nil),
 	}
 
 	for _, tt := range []struct {
@@ -137,6 +137,7 @@ func TestCallCommandHandler(t *testing.T) {
 		handlerFunc   any
 		wantResult    *CommandResult
 		wantErr       bool
+		wantFailure   bool
 		wantErrSubstr string
 	}{
 		{
@@ -189,49 +190,49 @@ func TestCallCommandHandler(t *testing.T) {
 			name:          "TypeMismatch",
 			info:          moveCmdInfo,
 			args:          map[string]any{"Direction": 123, "Steps": "1", "Speed": 1.0},
-			wantErr:       true,
+			wantFailure:   true,
 			wantErrSubstr: "type mismatch: got string, want int",
 		},
 		{
 			name:          "FloatToIntOverflow",
 			info:          moveCmdInfo,
 			args:          map[string]any{"Direction": "up", "Steps": float64(math.MaxInt64) + 100.0, "Speed": 1.0},
-			wantErr:       true,
+			wantFailure:   true,
 			wantErrSubstr: "integer overflow",
 		},
 		{
 			name:          "FloatToUintNegative",
 			info:          commandInfo{typ: reflect.TypeOf(struct{ Val uint }{}), handler: func(struct{ Val uint }) error { return nil }},
 			args:          map[string]any{"Val": -1.0},
-			wantErr:       true,
+			wantFailure:   true,
 			wantErrSubstr: "cannot assign negative float",
 		},
 		{
 			name:          "SliceTypeMismatch",
 			info:          sliceCmdInfo,
 			args:          map[string]any{"Names": []any{"Alice", 123}, "Scores": []any{"100"}},
-			wantErr:       true,
+			wantFailure:   true,
 			wantErrSubstr: "type mismatch: got string, want int",
 		},
 		{
 			name:          "SliceNilElement",
 			info:          sliceCmdInfo,
 			args:          map[string]any{"Names": []any{"Alice", nil}, "Scores": []any{100}},
-			wantErr:       true,
+			wantFailure:   true,
 			wantErrSubstr: "nil element at index 1",
 		},
 		{
 			name:          "SliceFloatToIntOverflow",
 			info:          sliceCmdInfo,
 			args:          map[string]any{"Names": []any{"A"}, "Scores": []any{float64(math.MaxInt64) + 100.0}},
-			wantErr:       true,
+			wantFailure:   true,
 			wantErrSubstr: "integer overflow",
 		},
 		{
 			name:          "SliceSetNilToNonNillable",
 			info:          moveCmdInfo,
 			args:          map[string]any{"Direction": "up", "Steps": nil, "Speed": 1.0},
-			wantErr:       true,
+			wantFailure:   true,
 			wantErrSubstr: "cannot set field Steps to nil",
 		},
 		{
@@ -242,7 +243,7 @@ func TestCallCommandHandler(t *testing.T) {
 				panic("intentional panic in test")
 			},
 			wantErr:       true,
-			wantErrSubstr: "panic in command handler",
+			wantErrSubstr: "panic in command handler for MoveCmd: intentional panic in test",
 		},
 	} {
 		t.Run(tt.name, func(t *testing.T) {
@@ -250,7 +251,7 @@ func TestCallCommandHandler(t *testing.T) {
 			if tt.handlerFunc != nil {
 				info.handler = tt.handlerFunc
 			}
-			result, err := callCommandHandler(nil, info, tt.args)
+			result, err := callCommandHandler(SpxExecutor{}, nil, info, tt.args)
 			if tt.wantErr {
 				if err == nil {
 					t.Fatal("expected error")
@@ -266,6 +267,17 @@ func TestCallCommandHandler(t *testing.T) {
 					t.Fatalf("unexpected error %v", err)
 				}
 			}
+			if tt.wantFailure {
+				if result == nil || result.Success {
+					t.Fatalf("got %#v, want a failed result", result)
+				}
+				for _, wantSubstr := range []string{"invalid arguments: ", tt.wantErrSubstr} {
+					if got := result.ErrorMessage; !strings.Contains(got, wantSubstr) {
+						t.Errorf("got %q, want substring %q", got, wantSubstr)
+					}
+				}
+				return
+			}
 			if got, want := result, tt.wantResult; !reflect.DeepEqual(got, want) {
 				t.Errorf("got %#v, want %#v", got, want)
 			}
@@ -299,7 +311,7 @@ func TestPopulateCommandFields(t *testing.T) {
 		"UintField":   100.0,
 		"hiddenField": "should not be set",
 		"NonExistent": "ignore me",
-	}); err != nil {
+	}, nil); err != nil {
 		t.Fatalf("unexpected error %v", err)
 	}
 
@@ -335,7 +347,7 @@ func TestPopulateCommandFields(t *testing.T) {
 	// Test nil args.
 	target = PopulateTarget{}
 	cmdVal = reflect.ValueOf(&target).Elem()
-	if err := populateCommandFields(cmdVal, nil); err != nil {
+	if err := populateCommandFields(cmdVal, nil, nil); err != nil {
 		t.Fatalf("unexpected error %v", err)
 	}
 	if got, want := target, (PopulateTarget{}); !reflect.DeepEqual(got, want) {
@@ -346,13 +358,137 @@ func TestPopulateCommandFields(t *testing.T) {
 	target = PopulateTarget{}
 	cmdVal = reflect.ValueOf(&target).Elem()
 	argsWithError := map[string]any{"IntField": "not a number"}
-	if err := populateCommandFields(cmdVal, argsWithError); err == nil {
+	if err := populateCommandFields(cmdVal, argsWithError, nil); err == nil {
 		t.Fatal("expected error")
 	} else if got, wantSubstr := err.Error(), "type mismatch"; !strings.Contains(got, wantSubstr) {
 		t.Errorf(`got %q, want substring %q`, got, wantSubstr)
 	}
 }
 
+func TestPopulateCommandFieldsNested(t *testing.T) {
+	type Point struct {
+		X, Y int
+	}
+	type Move struct {
+		Piece  string
+		Target Point
+	}
+	type NestedTarget struct {
+		Moves   []Move
+		Corners [2]Point
+		Scores  map[string]int
+		ByRow   map[int][]string
+		Best    *Point
+		Limit   *int
+		Others  []*Point
+	}
+	limit := 4
+
+	for _, tt := range []struct {
+		name       string
+		args       map[string]any
+		wantTarget NestedTarget
+		wantErr    string
+	}{
+		{
+			name: "Struct",
+			args: map[string]any{
+				"Moves": []any{
+					map[string]any{"Piece": "X", "Target": map[string]any{"X": 1.0, "Y": 2.0}},
+				},
+			},
+			wantTarget: NestedTarget{Moves: []Move{{Piece: "X", Target: Point{X: 1, Y: 2}}}},
+		},
+		{
+			name: "Array",
+			args: map[string]any{
+				"Corners": []any{map[string]any{"X": 0.0, "Y": 0.0}, map[string]any{"X": 2.0, "Y": 2.0}},
+			},
+			wantTarget: NestedTarget{Corners: [2]Point{{X: 0, Y: 0}, {X: 2, Y: 2}}},
+		},
+		{
+			name: "Map",
+			args: map[string]any{
+				"Scores": map[string]any{"alice": 3.0, "bob": 5.0},
+				"ByRow":  map[string]any{"1": []any{"X", "O"}},
+			},
+			wantTarget: NestedTarget{
+				Scores: map[string]int{"alice": 3, "bob": 5},
+				ByRow:  map[int][]string{1: {"X", "O"}},
+			},
+		},
+		{
+			name: "Pointer",
+			args: map[string]any{
+				"Best":   map[string]any{"X": 1.0},
+				"Limit":  4.0,
+				"Others": []any{nil, map[string]any{"Y": 3.0}},
+			},
+			wantTarget: NestedTarget{
+				Best:   &Point{X: 1},
+				Limit:  &limit,
+				Others: []*Point{nil, {Y: 3}},
+			},
+		},
+		{
+			name: "StructFieldTypeMismatch",
+			args: map[string]any{
+				"Moves": []any{
+					map[string]any{"Piece": "X"},
+					map[string]any{"Piece": "O"},
+					map[string]any{"Piece": "X", "Target": map[string]any{"X": "one"}},
+				},
+			},
+			wantErr: "field Moves[2].Target.X: type mismatch: got string, want int",
+		},
+		{
+			name:    "StructFromNonObject",
+			args:    map[string]any{"Best": "center"},
+			wantErr: "field Best: type mismatch: got string, want ai.Point",
+		},
+		{
+			name:    "ArrayLengthMismatch",
+			args:    map[string]any{"Corners": []any{map[string]any{}}},
+			wantErr: "field Corners: length mismatch: got 1, want 2",
+		},
+		{
+			name:    "MapValueTypeMismatch",
+			args:    map[string]any{"Scores": map[string]any{"alice": true}},
+			wantErr: `field Scores["alice"]: type mismatch: got bool, want int`,
+		},
+		{
+			name:    "MapInvalidIntegerKey",
+			args:    map[string]any{"ByRow": map[string]any{"first": []any{}}},
+			wantErr: `field ByRow["first"]: invalid integer key`,
+		},
+		{
+			name:    "NestedNilElement",
+			args:    map[string]any{"Moves": []any{map[string]any{"Target": nil}}},
+			wantErr: "field Moves[0].Target: cannot set field Target to nil",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var target NestedTarget
+			err := populateCommandFields(reflect.ValueOf(&target).Elem(), tt.args, nil)
+			if tt.wantErr != "" {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				if got, wantSubstr := err.Error(), tt.wantErr; !strings.Contains(got, wantSubstr) {
+					t.Errorf("got %q, want substring %q", got, wantSubstr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if got, want := target, tt.wantTarget; !reflect.DeepEqual(got, want) {
+				t.Errorf("got %#v, want %#v", got, want)
+			}
+		})
+	}
+}
+
 func TestSetField(t *testing.T) {
 	type Target struct {
 		StringField string
@@ -415,7 +551,7 @@ func TestSetField(t *testing.T) {
 				t.Fatalf("got %t, want %t", got, want)
 			}
 			argVal := reflect.ValueOf(tt.argValue)
-			err := setField(tt.fieldName, fieldVal, argVal)
+			err := setField(tt.fieldName, fieldVal, argVal, nil)
 			if tt.wantErr {
 				if err == nil {
 					t.Fatal("expected error")
@@ -533,7 +669,7 @@ func TestConvertAndSetSlice(t *testing.T) {
 				t.Fatalf("got %t, want %t", got, want)
 			}
 			argSliceVal := reflect.ValueOf(tt.argSliceValue)
-			err := convertAndSetSlice(fieldVal, argSliceVal, tt.fieldName)
+			err := convertAndSetSlice(fieldVal, argSliceVal, tt.fieldName, nil)
 			if tt.wantErr {
 				if err == nil {
 					t.Fatal("expected error")
@@ -586,7 +722,7 @@ func TestConvertSliceElement(t *testing.T) {
 			if elemVal.Kind() == reflect.Interface && !elemVal.IsNil() {
 				elemVal = elemVal.Elem()
 			}
-			gotConvertedVal, err := convertSliceElement(elemVal, tt.targetType)
+			gotConvertedVal, err := convertSliceElement(elemVal, tt.targetType, nil)
 			if tt.wantErr {
 				if err == nil {
 					t.Fatal("expected error")
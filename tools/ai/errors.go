@@ -0,0 +1,90 @@
+package ai
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrNoCommand indicates the AI ended an interaction sequence without
+	// asking for any command.
+	ErrNoCommand = errors.New("ai did not provide an initial command or any command during the interaction")
+
+	// ErrMaxTurnsExceeded indicates an interaction sequence was stopped after
+	// reaching [InteractionPolicy.MaxTurns] while the AI still asked for more
+	// commands.
+	ErrMaxTurnsExceeded = errors.New("ai interaction exceeded the maximum number of turns")
+
+	// ErrCanceled indicates an interaction sequence was canceled before it
+	// finished, e.g., via [ThinkHandle.Cancel] or because the calling script
+	// was aborted.
+	ErrCanceled = errors.New("ai interaction canceled")
+
+	// ErrRateLimitWaitExceeded indicates the backend asked to wait longer
+	// than [InteractionPolicy.RateLimitWaitTimeout] before another attempt.
+	ErrRateLimitWaitExceeded = errors.New("aborted due to excessive rate limit wait time")
+
+	// ErrSuperseded indicates a queued interaction sequence was dropped
+	// because a newer one was queued while the player uses
+	// [QueueLatestWins].
+	ErrSuperseded = errors.New("ai interaction superseded by a newer one")
+
+	// ErrBusy indicates an interaction sequence was dropped because the
+	// player was already thinking while it uses [QueueRejectWhileBusy].
+	ErrBusy = errors.New("ai player is busy thinking")
+
+	// ErrDropped indicates an interaction turn was dropped by the [Scheduler]
+	// because too many turns of players with the same or higher priority were
+	// waiting.
+	ErrDropped = errors.New("ai interaction dropped because too many interactions are waiting")
+
+	// ErrInvalidResponse indicates the [Transport] received a response from
+	// the backend that it cannot understand.
+	ErrInvalidResponse = errors.New("invalid ai response")
+
+	// ErrInvalidCommandArgs indicates the AI asked for a command with
+	// arguments that cannot be converted to the command's parameter types.
+	// Errors of specific arguments, e.g., type mismatches, are not reported
+	// with it, but to the AI as failed [CommandResult]s, so it can correct
+	// them.
+	ErrInvalidCommandArgs = errors.New("invalid command arguments")
+)
+
+// TransportError indicates that all attempts to call the [Transport] failed.
+type TransportError struct {
+	// Attempts is the number of transport calls that were made.
+	Attempts int
+
+	// Last is the error of the last attempt.
+	Last error
+}
+
+// Error implements [error].
+func (te *TransportError) Error() string {
+	return fmt.Sprintf("transport failed after %d attempts: %v", te.Attempts, te.Last)
+}
+
+// Unwrap returns the error of the last attempt.
+func (te *TransportError) Unwrap() error {
+	return te.Last
+}
+
+// CommandHandlerPanicError indicates that a command handler panicked.
+type CommandHandlerPanicError struct {
+	// CommandName is the name of the command whose handler panicked.
+	CommandName string
+
+	// Value is the value passed to panic.
+	Value any
+}
+
+// Error implements [error].
+func (chp *CommandHandlerPanicError) Error() string {
+	return fmt.Sprintf("panic in command handler for %s: %v", chp.CommandName, chp.Value)
+}
+
+// Unwrap returns Value if it is an error, or nil otherwise.
+func (chp *CommandHandlerPanicError) Unwrap() error {
+	err, _ := chp.Value.(error)
+	return err
+}
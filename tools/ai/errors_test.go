@@ -0,0 +1,53 @@
+package ai
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTransportError(t *testing.T) {
+	lastErr := &TooManyRequestsError{}
+	var err error = &TransportError{Attempts: 3, Last: lastErr}
+
+	if got, want := err.Error(), "transport failed after 3 attempts: too many requests"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	var tmrErr *TooManyRequestsError
+	if got, want := errors.As(err, &tmrErr), true; got != want {
+		t.Fatalf("got %t, want %t", got, want)
+	}
+	if got, want := tmrErr, lastErr; got != want {
+		t.Errorf("got %p, want %p", got, want)
+	}
+}
+
+func TestCommandHandlerPanicError(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		value      any
+		wantMsg    string
+		wantUnwrap error
+	}{
+		{
+			name:    "String",
+			value:   "boom",
+			wantMsg: "panic in command handler for MoveCmd: boom",
+		},
+		{
+			name:       "Error",
+			value:      ErrNoCommand,
+			wantMsg:    "panic in command handler for MoveCmd: " + ErrNoCommand.Error(),
+			wantUnwrap: ErrNoCommand,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := &CommandHandlerPanicError{CommandName: "MoveCmd", Value: tt.value}
+			if got, want := err.Error(), tt.wantMsg; got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+			if got, want := err.Unwrap(), tt.wantUnwrap; got != want {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package ai
+
+import (
+	"context"
+	"sync"
+
+	"github.com/goplus/spx/v2/pkg/spx"
+)
+
+// Executor runs the code of a [Player] in the runtime hosting it, such as a
+// running spx game.
+//
+// It decouples the player from the runtime, so the same AI logic can be used
+// in games, server-side simulations, CLIs and tests.
+type Executor interface {
+	// ExecuteNative runs fn, which may block on network requests or other I/O,
+	// and waits for it to finish. fn receives the context of the calling
+	// script, which is canceled when the script is aborted, and the owner of
+	// the calling script, if any.
+	ExecuteNative(fn func(ctx context.Context, owner any))
+
+	// Execute runs fn on behalf of owner where it can safely interact with
+	// the game, and waits for it to finish. It is used to call the handlers
+	// registered on the player.
+	Execute(owner any, fn func(ctx context.Context, owner any))
+
+	// IsAbort reports whether r, which was recovered from a panic in fn
+	// passed to [Executor.Execute], indicates the calling script was aborted
+	// rather than a handler failure.
+	IsAbort(r any) bool
+}
+
+// SpxExecutor is an [Executor] backed by the spx runtime. Handlers run in spx
+// coroutines, and blocking work yields to the spx scheduler, so the game is
+// not frozen while the player thinks.
+//
+// It is the default [Executor].
+type SpxExecutor struct{}
+
+// ExecuteNative implements [Executor].
+func (SpxExecutor) ExecuteNative(fn func(ctx context.Context, owner any)) {
+	spx.ExecuteNative(fn)
+}
+
+// Execute implements [Executor].
+func (SpxExecutor) Execute(owner any, fn func(ctx context.Context, owner any)) {
+	spx.Execute(owner, fn)
+}
+
+// IsAbort implements [Executor].
+func (SpxExecutor) IsAbort(r any) bool {
+	return spx.IsAbortThreadError(r)
+}
+
+// GoroutineExecutor is an [Executor] for headless use, such as server-side
+// simulations and CLIs, that runs everything on plain goroutines without any
+// game runtime. fn always receives [context.Background] and a nil owner in
+// [GoroutineExecutor.ExecuteNative].
+type GoroutineExecutor struct{}
+
+// ExecuteNative implements [Executor]. It runs fn on the calling goroutine.
+func (GoroutineExecutor) ExecuteNative(fn func(ctx context.Context, owner any)) {
+	fn(context.Background(), nil)
+}
+
+// Execute implements [Executor]. It runs fn on a new goroutine and waits for
+// it to finish.
+func (GoroutineExecutor) Execute(owner any, fn func(ctx context.Context, owner any)) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn(context.Background(), owner)
+	}()
+	<-done
+}
+
+// IsAbort implements [Executor]. It always returns false, as scripts are
+// never aborted without a game runtime.
+func (GoroutineExecutor) IsAbort(r any) bool {
+	return false
+}
+
+var (
+	// defaultExecutor holds the default instance of [Executor].
+	defaultExecutor   Executor = SpxExecutor{}
+	defaultExecutorMu sync.RWMutex
+)
+
+// DefaultExecutor returns the default [Executor] instance, which is used by
+// all players that don't set their own via [Player.SetExecutor].
+func DefaultExecutor() Executor {
+	defaultExecutorMu.RLock()
+	defer defaultExecutorMu.RUnlock()
+	return defaultExecutor
+}
+
+// SetDefaultExecutor sets the default instance of [Executor]. It resets to
+// [SpxExecutor] if nil is provided.
+func SetDefaultExecutor(e Executor) {
+	defaultExecutorMu.Lock()
+	defer defaultExecutorMu.Unlock()
+	if e == nil {
+		e = SpxExecutor{}
+	}
+	defaultExecutor = e
+}
+
+// executor returns the [Executor] used by the player. It falls back to
+// [DefaultExecutor] if no custom one is set via [Player.SetExecutor]. The
+// caller must hold p.mu.
+func (p *Player) executor() Executor {
+	if p.customExecutor != nil {
+		return p.customExecutor
+	}
+	return DefaultExecutor()
+}
+
+// SetExecutor sets a custom [Executor] for the player, e.g.,
+// [GoroutineExecutor] to use it without a running game. It resets to
+// [DefaultExecutor] if nil is provided.
+func (p *Player) SetExecutor(e Executor) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.customExecutor = e
+}
+
+// execute runs fn on behalf of owner via the player's [Executor]. See
+// [Executor.Execute].
+func (p *Player) execute(owner any, fn func(ctx context.Context, owner any)) {
+	p.mu.RLock()
+	e := p.executor()
+	p.mu.RUnlock()
+	e.Execute(owner, fn)
+}
+
+// executeNative runs fn via the player's [Executor]. See
+// [Executor.ExecuteNative].
+func (p *Player) executeNative(fn func(ctx context.Context, owner any)) {
+	p.mu.RLock()
+	e := p.executor()
+	p.mu.RUnlock()
+	e.ExecuteNative(fn)
+}
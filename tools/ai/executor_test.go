@@ -0,0 +1,152 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+// recordingExecutor is an [Executor] that runs everything synchronously and
+// counts the calls.
+type recordingExecutor struct {
+	mu          sync.Mutex
+	nativeCalls int
+	calls       int
+	isAbort     func(r any) bool
+}
+
+func (e *recordingExecutor) ExecuteNative(fn func(ctx context.Context, owner any)) {
+	e.mu.Lock()
+	e.nativeCalls++
+	e.mu.Unlock()
+	fn(context.Background(), "owner")
+}
+
+func (e *recordingExecutor) Execute(owner any, fn func(ctx context.Context, owner any)) {
+	e.mu.Lock()
+	e.calls++
+	e.mu.Unlock()
+	fn(context.Background(), owner)
+}
+
+func (e *recordingExecutor) IsAbort(r any) bool {
+	return e.isAbort != nil && e.isAbort(r)
+}
+
+func TestDefaultExecutor(t *testing.T) {
+	originalExecutor := DefaultExecutor()
+	t.Cleanup(func() { SetDefaultExecutor(originalExecutor) })
+
+	if _, ok := DefaultExecutor().(SpxExecutor); !ok {
+		t.Errorf("got %T, want SpxExecutor", DefaultExecutor())
+	}
+
+	SetDefaultExecutor(GoroutineExecutor{})
+	if _, ok := DefaultExecutor().(GoroutineExecutor); !ok {
+		t.Errorf("got %T, want GoroutineExecutor", DefaultExecutor())
+	}
+
+	SetDefaultExecutor(nil)
+	if _, ok := DefaultExecutor().(SpxExecutor); !ok {
+		t.Errorf("got %T, want SpxExecutor", DefaultExecutor())
+	}
+}
+
+func TestPlayerExecutor(t *testing.T) {
+	p := &Player{}
+	if got, want := p.executor(), DefaultExecutor(); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	e := &recordingExecutor{}
+	p.SetExecutor(e)
+	if got, want := p.executor(), Executor(e); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	p.SetExecutor(nil)
+	if got, want := p.executor(), DefaultExecutor(); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestPlayerThinkExecutor(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		executor func() Executor
+	}{
+		{"GoroutineExecutor", func() Executor { return GoroutineExecutor{} }},
+		{"CustomExecutor", func() Executor { return &recordingExecutor{} }},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Player{}
+			p.SetRateLimiter(&RateLimiter{})
+			p.SetTransport(&mockTransport{
+				InteractFunc: func(ctx context.Context, req Request) (Response, error) {
+					if req.ContinuationTurn == 0 {
+						return Response{CommandName: "MoveCmd", CommandArgs: map[string]any{"Steps": 1}}, nil
+					}
+					return Response{Text: "Done."}, nil
+				},
+			})
+			executor := tt.executor()
+			p.SetExecutor(executor)
+			var (
+				moved     int
+				responses int
+			)
+			XGot_Player_XGox_OnCmd(p, func(cmd MoveCmd) error {
+				moved += cmd.Steps
+				return nil
+			})
+			p.OnResponse(func(resp Response) {
+				responses++
+			})
+
+			outcome := p.ThinkResult__1("move")
+
+			if outcome.Err != nil {
+				t.Fatalf("unexpected error: %v", outcome.Err)
+			}
+			if got, want := moved, 1; got != want {
+				t.Errorf("got %d, want %d", got, want)
+			}
+			if got, want := responses, 2; got != want {
+				t.Errorf("got %d, want %d", got, want)
+			}
+			if re, ok := executor.(*recordingExecutor); ok {
+				if got, want := re.nativeCalls, 1; got != want {
+					t.Errorf("got %d native calls, want %d", got, want)
+				}
+				// 2 responses and 1 command.
+				if got, want := re.calls, 3; got != want {
+					t.Errorf("got %d calls, want %d", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPlayerThinkExecutorAbort(t *testing.T) {
+	errAbort := errors.New("abort")
+	p := &Player{}
+	p.SetRateLimiter(&RateLimiter{})
+	p.SetTransport(&mockTransport{
+		InteractFunc: func(ctx context.Context, req Request) (Response, error) {
+			if req.ContinuationTurn == 0 {
+				return Response{CommandName: "MoveCmd", CommandArgs: map[string]any{"Steps": 1}}, nil
+			}
+			return Response{Text: "Done."}, nil
+		},
+	})
+	p.SetExecutor(&recordingExecutor{isAbort: func(r any) bool { return r == errAbort }})
+	XGot_Player_XGox_OnCmd(p, func(cmd MoveCmd) error { panic(errAbort) })
+
+	outcome := p.ThinkResult__1("move")
+
+	var panicErr *CommandHandlerPanicError
+	if errors.As(outcome.Err, &panicErr) {
+		t.Errorf("got %v, want aborts not reported as panics", outcome.Err)
+	}
+}
@@ -0,0 +1,89 @@
+// Package filestore provides a MemoryStore implementation for AI player memory
+// using the local file system, suitable for native environments.
+package filestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/goplus/builder/tools/ai"
+)
+
+// fileStore implements [ai.MemoryStore] by saving each snapshot as a file in
+// a directory.
+type fileStore struct {
+	// dir is the directory where snapshot files are saved.
+	dir string
+}
+
+// New creates a new [ai.MemoryStore] that saves snapshots as files in dir. The
+// directory is created on the first save if it does not exist.
+func New(dir string) ai.MemoryStore {
+	return &fileStore{dir: dir}
+}
+
+// path returns the path of the snapshot file for key. Keys are escaped so
+// that any key maps to a single file within the directory.
+func (s *fileStore) path(key string) string {
+	return filepath.Join(s.dir, url.PathEscape(key)+".json")
+}
+
+// Load implements [ai.MemoryStore].
+func (s *fileStore) Load(ctx context.Context, key string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(s.path(key))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s", ai.ErrMemoryNotFound, key)
+		}
+		return nil, fmt.Errorf("failed to read snapshot file: %w", err)
+	}
+	return data, nil
+}
+
+// Save implements [ai.MemoryStore]. It writes to a temporary file first and
+// renames it, so an interrupted save never leaves a partial snapshot behind.
+func (s *fileStore) Save(ctx context.Context, key string, data []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	f, err := os.CreateTemp(s.dir, ".snapshot-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	tmpPath := f.Name()
+	defer os.Remove(tmpPath)
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close temporary file: %w", err)
+	}
+	if err := os.Rename(tmpPath, s.path(key)); err != nil {
+		return fmt.Errorf("failed to rename temporary file: %w", err)
+	}
+	return nil
+}
+
+// Delete implements [ai.MemoryStore].
+func (s *fileStore) Delete(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := os.Remove(s.path(key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to remove snapshot file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package filestore
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/goplus/builder/tools/ai"
+)
+
+func TestFileStore(t *testing.T) {
+	ctx := context.Background()
+	dir := filepath.Join(t.TempDir(), "memory")
+	store := New(dir)
+
+	if _, err := store.Load(ctx, "tutor"); !errors.Is(err, ai.ErrMemoryNotFound) {
+		t.Fatalf("got %v, want %v", err, ai.ErrMemoryNotFound)
+	}
+
+	for _, data := range []string{`{"version":1}`, `{"version":1,"role":"tutor"}`} {
+		if err := store.Save(ctx, "tutor", []byte(data)); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		got, err := store.Load(ctx, "tutor")
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if got, want := string(got), data; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+
+	if err := store.Save(ctx, "../escape/key", []byte("{}")); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if got, want := len(entries), 2; got != want {
+		t.Errorf("got %d, want %d", got, want)
+	}
+
+	for range 2 {
+		if err := store.Delete(ctx, "tutor"); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+	if _, err := store.Load(ctx, "tutor"); !errors.Is(err, ai.ErrMemoryNotFound) {
+		t.Errorf("got %v, want %v", err, ai.ErrMemoryNotFound)
+	}
+}
+
+func TestFileStoreCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	store := New(t.TempDir())
+	if err := store.Save(ctx, "tutor", []byte("{}")); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want %v", err, context.Canceled)
+	}
+	if _, err := store.Load(ctx, "tutor"); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want %v", err, context.Canceled)
+	}
+}
@@ -8,9 +8,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 
 	"github.com/goplus/builder/tools/ai"
+	"github.com/goplus/builder/tools/ai/internal/httpstatus"
+	"github.com/goplus/builder/tools/ai/internal/sse"
 )
 
 // httpTransport implements [ai.Transport] using standard net/http package.
@@ -57,6 +60,9 @@ func WithTokenProvider(provider func() string) Option {
 // New creates a new [ai.Transport] suitable for standard HTTP environments. It
 // uses net/http package to make network requests. By default, it uses
 // "/api/ai-interaction" endpoint and sends no Authorization token.
+//
+// The returned [ai.Transport] also implements [ai.StreamingTransport] using
+// Server-Sent Events.
 func New(opts ...Option) ai.Transport {
 	t := &httpTransport{
 		client:        http.DefaultClient,
@@ -93,6 +99,40 @@ func (t *httpTransport) Interact(ctx context.Context, req ai.Request) (ai.Respon
 	return resp, nil
 }
 
+// InteractStream implements [ai.StreamingTransport].
+func (t *httpTransport) InteractStream(ctx context.Context, req ai.Request, onText func(delta string)) (ai.Response, error) {
+	reqBody, err := json.Marshal(req)
+	if err != nil {
+		return ai.Response{}, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := t.buildRequest(ctx, "POST", "/turns", reqBody)
+	if err != nil {
+		return ai.Response{}, err
+	}
+	httpReq.Header.Set("Accept", "text/event-stream, application/json")
+
+	httpResp, err := t.client.Do(httpReq)
+	if err != nil {
+		return ai.Response{}, fmt.Errorf("failed to execute http request: %w", err)
+	}
+
+	// Fall back to a regular response if the server does not support streaming.
+	if mediaType, _, _ := mime.ParseMediaType(httpResp.Header.Get("Content-Type")); mediaType != "text/event-stream" || httpResp.StatusCode != http.StatusOK {
+		var resp ai.Response
+		if err := handleResponse(httpResp, &resp); err != nil {
+			return ai.Response{}, err
+		}
+		if resp.Text != "" {
+			onText(resp.Text)
+		}
+		return resp, nil
+	}
+	defer httpResp.Body.Close()
+
+	return sse.ReadResponse(httpResp.Body, onText)
+}
+
 // Archive implements [ai.Transport].
 func (t *httpTransport) Archive(ctx context.Context, turns []ai.Turn, existingArchive string) (ai.ArchivedHistory, error) {
 	reqBody, err := json.Marshal(map[string]any{
@@ -147,18 +187,20 @@ func handleResponse(resp *http.Response, target any) error {
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		if resp.StatusCode == http.StatusTooManyRequests {
-			retryAfter := ai.RetryAfterFromHeader(resp.Header.Get("Retry-After"))
-			return &ai.TooManyRequestsError{
-				RetryAfter: retryAfter,
-				Err:        fmt.Errorf("failed to fetch with status: %s: %s", resp.Status, body),
+		err := httpstatus.Error(resp, body)
+		if resp.StatusCode == http.StatusForbidden && resp.Header.Get("Retry-After") != "" {
+			// The XBuilder backend reports an exhausted long-window quota
+			// as 403 with Retry-After.
+			return &ai.QuotaExceededError{
+				RetryAfter: ai.RetryAfterFromHeader(resp.Header.Get("Retry-After")),
+				Err:        err,
 			}
 		}
-		return fmt.Errorf("failed to fetch with status: %s: %s", resp.Status, body)
+		return err
 	}
 
 	if err := json.Unmarshal(body, target); err != nil {
-		return fmt.Errorf("failed to unmarshal response json: %w", err)
+		return fmt.Errorf("%w: failed to unmarshal response json: %w", ai.ErrInvalidResponse, err)
 	}
 
 	return nil
@@ -0,0 +1,27 @@
+// Package httpstatus converts non-OK HTTP responses of AI backends to the
+// errors of package ai, shared by the HTTP-based transports.
+package httpstatus
+
+import (
+	"net/http"
+
+	"github.com/goplus/builder/tools/ai"
+)
+
+// Error returns the error for resp with a non-OK status and its already read
+// body. It is an [ai.StatusError], wrapped in [ai.TooManyRequestsError] with
+// the Retry-After hint if the status is 429.
+func Error(resp *http.Response, body []byte) error {
+	statusErr := &ai.StatusError{
+		StatusCode: resp.StatusCode,
+		Status:     http.StatusText(resp.StatusCode),
+		Body:       string(body),
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return &ai.TooManyRequestsError{
+			RetryAfter: ai.RetryAfterFromHeader(resp.Header.Get("Retry-After")),
+			Err:        statusErr,
+		}
+	}
+	return statusErr
+}
@@ -0,0 +1,54 @@
+package httpstatus
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/goplus/builder/tools/ai"
+)
+
+func TestError(t *testing.T) {
+	t.Run("TooManyRequests", func(t *testing.T) {
+		resp := &http.Response{
+			StatusCode: http.StatusTooManyRequests,
+			Header:     http.Header{"Retry-After": []string{"3"}},
+		}
+		err := Error(resp, []byte("slow down"))
+
+		var tmrErr *ai.TooManyRequestsError
+		if !errors.As(err, &tmrErr) {
+			t.Fatalf("got %v, want *ai.TooManyRequestsError", err)
+		}
+		if got, want := tmrErr.RetryAfter, 3*time.Second; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		var statusErr *ai.StatusError
+		if !errors.As(err, &statusErr) {
+			t.Fatalf("got %v, want *ai.StatusError", err)
+		}
+		if got, want := statusErr.Body, "slow down"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("OtherStatus", func(t *testing.T) {
+		resp := &http.Response{
+			StatusCode: http.StatusForbidden,
+			Header:     http.Header{"Retry-After": []string{"3"}},
+		}
+		err := Error(resp, nil)
+
+		var statusErr *ai.StatusError
+		if !errors.As(err, &statusErr) {
+			t.Fatalf("got %v, want *ai.StatusError", err)
+		}
+		if got, want := *statusErr, (ai.StatusError{StatusCode: http.StatusForbidden, Status: "Forbidden"}); got != want {
+			t.Errorf("got %#v, want %#v", got, want)
+		}
+		if ai.IsQuotaExceeded(err) {
+			t.Errorf("got %v, want no quota exceeded error", err)
+		}
+	})
+}
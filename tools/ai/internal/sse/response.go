@@ -0,0 +1,81 @@
+package sse
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/goplus/builder/tools/ai"
+)
+
+// errStreamDone is used to stop reading the stream after the terminal event.
+var errStreamDone = errors.New("stream done")
+
+// ReadResponse reads a Server-Sent Events stream of an AI interaction turn
+// from r. It calls onText for each "text_delta" event and returns the final
+// [ai.Response] carried by the terminal "done" event. A terminal "error" event
+// is returned as an [ai.StreamError], wrapped in [ai.TooManyRequestsError] or
+// [ai.QuotaExceededError] if its reason says so.
+func ReadResponse(r io.Reader, onText func(delta string)) (ai.Response, error) {
+	var (
+		resp      ai.Response
+		streamErr error
+		done      bool
+	)
+	if err := Read(r, func(event Event) error {
+		switch event.Name {
+		case "text_delta":
+			var delta struct {
+				Text string `json:"text"`
+			}
+			if err := json.Unmarshal([]byte(event.Data), &delta); err != nil {
+				return fmt.Errorf("%w: failed to unmarshal text delta json: %w", ai.ErrInvalidResponse, err)
+			}
+			if delta.Text != "" {
+				onText(delta.Text)
+			}
+		case "done":
+			if err := json.Unmarshal([]byte(event.Data), &resp); err != nil {
+				return fmt.Errorf("%w: failed to unmarshal response json: %w", ai.ErrInvalidResponse, err)
+			}
+			done = true
+			return errStreamDone
+		case "error":
+			var errData struct {
+				Reason  string `json:"reason"`
+				Message string `json:"message"`
+			}
+			if err := json.Unmarshal([]byte(event.Data), &errData); err != nil {
+				return fmt.Errorf("%w: failed to unmarshal stream error json: %w", ai.ErrInvalidResponse, err)
+			}
+			streamErr = streamError(&ai.StreamError{
+				Reason:  errData.Reason,
+				Message: errData.Message,
+			})
+			return errStreamDone
+		}
+		return nil
+	}); err != nil && !errors.Is(err, errStreamDone) {
+		return ai.Response{}, fmt.Errorf("failed to read stream: %w", err)
+	}
+	if streamErr != nil {
+		return ai.Response{}, streamErr
+	}
+	if !done {
+		return ai.Response{}, fmt.Errorf("%w: stream ended unexpectedly", ai.ErrInvalidResponse)
+	}
+	return resp, nil
+}
+
+// streamError wraps err in the typed error for rate limiting or quota
+// exhaustion if its reason says so.
+func streamError(err *ai.StreamError) error {
+	switch err.Reason {
+	case ai.StreamErrorReasonRateLimited:
+		return &ai.TooManyRequestsError{Err: err}
+	case ai.StreamErrorReasonQuotaExceeded:
+		return &ai.QuotaExceededError{Err: err}
+	}
+	return err
+}
@@ -0,0 +1,92 @@
+package sse
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/goplus/builder/tools/ai"
+)
+
+func TestReadResponse(t *testing.T) {
+	t.Run("TextAndCommand", func(t *testing.T) {
+		stream := "event: text_delta\ndata: {\"text\":\"Let me \"}\n\n" +
+			"event: text_delta\ndata: {\"text\":\"move.\"}\n\n" +
+			"event: done\ndata: {\"text\":\"Let me move.\",\"commandName\":\"Move\",\"commandArgs\":{\"Steps\":2}}\n\n"
+
+		var deltas []string
+		resp, err := ReadResponse(strings.NewReader(stream), func(delta string) {
+			deltas = append(deltas, delta)
+		})
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if got, want := deltas, []string{"Let me ", "move."}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %#v, want %#v", got, want)
+		}
+		if got, want := resp, (ai.Response{
+			Text:        "Let me move.",
+			CommandName: "Move",
+			CommandArgs: map[string]any{"Steps": 2.0},
+		}); !reflect.DeepEqual(got, want) {
+			t.Errorf("got %#v, want %#v", got, want)
+		}
+	})
+
+	t.Run("ErrorEvent", func(t *testing.T) {
+		stream := "event: text_delta\ndata: {\"text\":\"Hi\"}\n\n" +
+			"event: error\ndata: {\"reason\":\"streamFailed\",\"message\":\"boom\"}\n\n"
+
+		_, err := ReadResponse(strings.NewReader(stream), func(string) {})
+		var streamErr *ai.StreamError
+		if !errors.As(err, &streamErr) {
+			t.Fatalf("got %v, want *ai.StreamError", err)
+		}
+		if got, want := *streamErr, (ai.StreamError{Reason: "streamFailed", Message: "boom"}); got != want {
+			t.Errorf("got %#v, want %#v", got, want)
+		}
+		var tmrErr *ai.TooManyRequestsError
+		if errors.As(err, &tmrErr) {
+			t.Errorf("got %v, want no *ai.TooManyRequestsError", err)
+		}
+	})
+
+	t.Run("RateLimitedErrorEvent", func(t *testing.T) {
+		stream := "event: error\ndata: {\"reason\":\"rateLimited\",\"message\":\"slow down\"}\n\n"
+
+		_, err := ReadResponse(strings.NewReader(stream), func(string) {})
+		var tmrErr *ai.TooManyRequestsError
+		if !errors.As(err, &tmrErr) {
+			t.Fatalf("got %v, want *ai.TooManyRequestsError", err)
+		}
+		var streamErr *ai.StreamError
+		if !errors.As(err, &streamErr) {
+			t.Errorf("got %v, want *ai.StreamError", err)
+		}
+	})
+
+	t.Run("QuotaExceededErrorEvent", func(t *testing.T) {
+		stream := "event: error\ndata: {\"reason\":\"quotaExceeded\",\"message\":\"out of quota\"}\n\n"
+
+		_, err := ReadResponse(strings.NewReader(stream), func(string) {})
+		if !ai.IsQuotaExceeded(err) {
+			t.Errorf("got %v, want quota exceeded error", err)
+		}
+	})
+
+	t.Run("UnexpectedEnd", func(t *testing.T) {
+		stream := "event: text_delta\ndata: {\"text\":\"Hi\"}\n\n"
+
+		_, err := ReadResponse(strings.NewReader(stream), func(string) {})
+		if err == nil {
+			t.Fatal("expected error")
+		}
+		if got, wantSubstr := err.Error(), "ended unexpectedly"; !strings.Contains(got, wantSubstr) {
+			t.Errorf("got %q, want substring %q", got, wantSubstr)
+		}
+		if !errors.Is(err, ai.ErrInvalidResponse) {
+			t.Errorf("got %v, want %v", err, ai.ErrInvalidResponse)
+		}
+	})
+}
@@ -0,0 +1,75 @@
+// Package sse provides a minimal parser for Server-Sent Events streams as
+// described in https://html.spec.whatwg.org/multipage/server-sent-events.html,
+// shared by the [ai.StreamingTransport] implementations.
+package sse
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+)
+
+// maxLineSize is the maximum size of a single line in the stream.
+const maxLineSize = 1 << 20
+
+// Event is a single event dispatched from a Server-Sent Events stream.
+type Event struct {
+	// Name is the event type. It defaults to "message" if not specified by
+	// the stream.
+	Name string
+
+	// Data is the event data. Multiple data lines are joined with "\n".
+	Data string
+}
+
+// Read reads events from r and calls fn for each dispatched event. It returns
+// when r reaches EOF, reading from r fails, or fn returns a non-nil error,
+// which is then returned as is.
+func Read(r io.Reader, fn func(Event) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), maxLineSize)
+
+	var (
+		name    string
+		data    strings.Builder
+		hasData bool
+	)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			// Dispatch the event.
+			if hasData {
+				event := Event{Name: name, Data: data.String()}
+				if event.Name == "" {
+					event.Name = "message"
+				}
+				if err := fn(event); err != nil {
+					return err
+				}
+			}
+			name = ""
+			data.Reset()
+			hasData = false
+			continue
+		}
+		if line[0] == ':' {
+			// Comment line.
+			continue
+		}
+
+		field, value, _ := bytes.Cut(line, []byte(":"))
+		value = bytes.TrimPrefix(value, []byte(" "))
+		switch string(field) {
+		case "event":
+			name = string(value)
+		case "data":
+			if hasData {
+				data.WriteByte('\n')
+			}
+			data.Write(value)
+			hasData = true
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,80 @@
+package sse
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRead(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		stream string
+		want   []Event
+	}{
+		{
+			name:   "SingleEvent",
+			stream: "event: text_delta\ndata: {\"text\":\"Hi\"}\n\n",
+			want:   []Event{{Name: "text_delta", Data: `{"text":"Hi"}`}},
+		},
+		{
+			name:   "DefaultEventName",
+			stream: "data: hello\n\n",
+			want:   []Event{{Name: "message", Data: "hello"}},
+		},
+		{
+			name:   "MultiLineData",
+			stream: "data: a\ndata: b\n\n",
+			want:   []Event{{Name: "message", Data: "a\nb"}},
+		},
+		{
+			name:   "CommentsAndUnknownFields",
+			stream: ": keep-alive\nid: 1\nevent: done\ndata:{}\n\n",
+			want:   []Event{{Name: "done", Data: "{}"}},
+		},
+		{
+			name:   "CRLF",
+			stream: "event: done\r\ndata: {}\r\n\r\n",
+			want:   []Event{{Name: "done", Data: "{}"}},
+		},
+		{
+			name:   "NoDataNoDispatch",
+			stream: "event: ping\n\n",
+			want:   nil,
+		},
+		{
+			name:   "UnterminatedEventIgnored",
+			stream: "event: a\ndata: 1\n\nevent: b\ndata: 2",
+			want:   []Event{{Name: "a", Data: "1"}},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []Event
+			if err := Read(strings.NewReader(tt.stream), func(e Event) error {
+				got = append(got, e)
+				return nil
+			}); err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("CallbackError", func(t *testing.T) {
+		wantErr := errors.New("stop")
+		var count int
+		err := Read(strings.NewReader("data: 1\n\ndata: 2\n\n"), func(e Event) error {
+			count++
+			return wantErr
+		})
+		if !errors.Is(err, wantErr) {
+			t.Errorf("got %v, want %v", err, wantErr)
+		}
+		if got, want := count, 1; got != want {
+			t.Errorf("got %d, want %d", got, want)
+		}
+	})
+}
@@ -1,6 +1,9 @@
 package ai
 
-import "sync"
+import (
+	"maps"
+	"sync"
+)
 
 var (
 	// defaultKnowledgeBase is the default knowledge base for AI players.
@@ -23,3 +26,31 @@ func SetDefaultKnowledgeBase(kb map[string]any) {
 	defer defaultKnowledgeBaseMu.Unlock()
 	defaultKnowledgeBase = kb
 }
+
+// mergeKnowledgeBase returns a new knowledge base that layers override on top
+// of base. Keys in override take precedence over keys in base, except when
+// both values are map[string]any, in which case they are merged recursively
+// following the same rules. Neither base nor override is modified.
+//
+// If either of them is empty, the other one is returned as is.
+func mergeKnowledgeBase(base, override map[string]any) map[string]any {
+	if len(override) == 0 {
+		return base
+	}
+	if len(base) == 0 {
+		return override
+	}
+
+	merged := make(map[string]any, len(base)+len(override))
+	maps.Copy(merged, base)
+	for k, v := range override {
+		baseMap, baseIsMap := merged[k].(map[string]any)
+		overrideMap, overrideIsMap := v.(map[string]any)
+		if baseIsMap && overrideIsMap {
+			merged[k] = mergeKnowledgeBase(baseMap, overrideMap)
+			continue
+		}
+		merged[k] = v
+	}
+	return merged
+}
@@ -28,3 +28,101 @@ func TestDefaultKnowledgeBase(t *testing.T) {
 		t.Errorf("got %#v, want nil", got)
 	}
 }
+
+func TestMergeKnowledgeBase(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		base     map[string]any
+		override map[string]any
+		want     map[string]any
+	}{
+		{
+			name: "NilBoth",
+			want: nil,
+		},
+		{
+			name: "NilOverride",
+			base: map[string]any{"a": 1},
+			want: map[string]any{"a": 1},
+		},
+		{
+			name:     "NilBase",
+			override: map[string]any{"b": 2},
+			want:     map[string]any{"b": 2},
+		},
+		{
+			name:     "OverrideWins",
+			base:     map[string]any{"a": 1, "b": 2},
+			override: map[string]any{"b": 3, "c": 4},
+			want:     map[string]any{"a": 1, "b": 3, "c": 4},
+		},
+		{
+			name: "NestedMapsMergeRecursively",
+			base: map[string]any{
+				"prices": map[string]any{"apple": 1, "sword": 10},
+				"world":  "Overworld",
+			},
+			override: map[string]any{
+				"prices": map[string]any{"sword": 8, "shield": 5},
+			},
+			want: map[string]any{
+				"prices": map[string]any{"apple": 1, "sword": 8, "shield": 5},
+				"world":  "Overworld",
+			},
+		},
+		{
+			name:     "NonMapReplacesMap",
+			base:     map[string]any{"prices": map[string]any{"apple": 1}},
+			override: map[string]any{"prices": "secret"},
+			want:     map[string]any{"prices": "secret"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, want := mergeKnowledgeBase(tt.base, tt.override), tt.want; !reflect.DeepEqual(got, want) {
+				t.Errorf("got %#v, want %#v", got, want)
+			}
+		})
+	}
+
+	t.Run("InputsUnmodified", func(t *testing.T) {
+		base := map[string]any{"nested": map[string]any{"a": 1}}
+		override := map[string]any{"nested": map[string]any{"b": 2}}
+
+		mergeKnowledgeBase(base, override)
+
+		if got, want := base, (map[string]any{"nested": map[string]any{"a": 1}}); !reflect.DeepEqual(got, want) {
+			t.Errorf("got %#v, want %#v", got, want)
+		}
+		if got, want := override, (map[string]any{"nested": map[string]any{"b": 2}}); !reflect.DeepEqual(got, want) {
+			t.Errorf("got %#v, want %#v", got, want)
+		}
+	})
+}
+
+func TestPlayerKnowledgeBase(t *testing.T) {
+	originalKB := DefaultKnowledgeBase()
+	t.Cleanup(func() { SetDefaultKnowledgeBase(originalKB) })
+	SetDefaultKnowledgeBase(map[string]any{
+		"worldName": "TestWorld",
+		"prices":    map[string]any{"apple": 1},
+	})
+
+	shopkeeper := &Player{}
+	shopkeeper.SetKnowledge__0(map[string]any{
+		"prices": map[string]any{"sword": 10},
+	})
+	shopkeeper.SetKnowledge__1("secret", "the back door")
+
+	guard := &Player{}
+
+	if got, want := shopkeeper.knowledgeBase(), (map[string]any{
+		"worldName": "TestWorld",
+		"prices":    map[string]any{"apple": 1, "sword": 10},
+		"secret":    "the back door",
+	}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+	if got, want := guard.knowledgeBase(), DefaultKnowledgeBase(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+}
@@ -0,0 +1,212 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// SnapshotVersion is the version of the format produced by [Player.Snapshot].
+const SnapshotVersion = 1
+
+// playerSnapshot is the JSON format produced by [Player.Snapshot].
+type playerSnapshot struct {
+	Version         int            `json:"version"`
+	Role            string         `json:"role,omitempty"`
+	RoleContext     map[string]any `json:"roleContext,omitempty"`
+	History         []Turn         `json:"history,omitempty"`
+	ArchivedHistory string         `json:"archivedHistory,omitempty"`
+}
+
+// Snapshot serializes the player's memory, including its role, role context,
+// interaction history and archived history, to a versioned JSON format that
+// can be passed to [Player.Restore].
+func (p *Player) Snapshot() ([]byte, error) {
+	p.mu.RLock()
+	snapshot := playerSnapshot{
+		Version:         SnapshotVersion,
+		Role:            p.role,
+		RoleContext:     p.roleContext,
+		History:         p.history,
+		ArchivedHistory: p.archivedHistory,
+	}
+	data, err := json.Marshal(snapshot)
+	p.mu.RUnlock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal snapshot: %w", err)
+	}
+	return data, nil
+}
+
+// Restore replaces the player's memory with the one serialized by
+// [Player.Snapshot]. It is intended to be called while the player is not
+// thinking; turns of an ongoing interaction sequence are appended to the
+// restored history.
+func (p *Player) Restore(data []byte) error {
+	var snapshot playerSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return fmt.Errorf("failed to unmarshal snapshot: %w", err)
+	}
+	if snapshot.Version < 1 || snapshot.Version > SnapshotVersion {
+		return fmt.Errorf("unsupported snapshot version %d", snapshot.Version)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.role = snapshot.Role
+	p.roleContext = snapshot.RoleContext
+	p.history = snapshot.History
+	p.historyTokens = nil
+	p.archivedHistory = snapshot.ArchivedHistory
+	if p.archiveInProgress {
+		// The in-progress archive was prepared from the replaced history, so
+		// stop it and discard its result if it already finished.
+		p.archiveDiscarded = true
+		if p.archiveCancel != nil {
+			p.archiveCancel()
+		}
+	}
+	return nil
+}
+
+// ErrMemoryNotFound indicates that no memory is saved under the given key in
+// a [MemoryStore].
+var ErrMemoryNotFound = errors.New("memory not found")
+
+// MemoryStore persists player memory snapshots produced by [Player.Snapshot]
+// across game sessions.
+type MemoryStore interface {
+	// Load returns the snapshot saved under key. It returns an error wrapping
+	// [ErrMemoryNotFound] if there is none.
+	Load(ctx context.Context, key string) ([]byte, error)
+
+	// Save saves the snapshot under key, replacing any existing one.
+	Save(ctx context.Context, key string, data []byte) error
+
+	// Delete deletes the snapshot saved under key. It succeeds if there is
+	// none.
+	Delete(ctx context.Context, key string) error
+}
+
+// ErrMemoryStoreNotSet indicates that the memory store has not been
+// configured via [SetDefaultMemoryStore] or [Player.SetMemoryStore].
+var ErrMemoryStoreNotSet = errors.New("memory store not set")
+
+// notSetMemoryStore is the [MemoryStore] implementation that always returns
+// [ErrMemoryStoreNotSet].
+type notSetMemoryStore struct{}
+
+// Load implements [MemoryStore].
+func (s *notSetMemoryStore) Load(_ context.Context, _ string) ([]byte, error) {
+	return nil, ErrMemoryStoreNotSet
+}
+
+// Save implements [MemoryStore].
+func (s *notSetMemoryStore) Save(_ context.Context, _ string, _ []byte) error {
+	return ErrMemoryStoreNotSet
+}
+
+// Delete implements [MemoryStore].
+func (s *notSetMemoryStore) Delete(_ context.Context, _ string) error {
+	return ErrMemoryStoreNotSet
+}
+
+var (
+	// defaultMemoryStore holds the default instance of [MemoryStore].
+	defaultMemoryStore   MemoryStore = &notSetMemoryStore{}
+	defaultMemoryStoreMu sync.RWMutex
+)
+
+// DefaultMemoryStore returns the default [MemoryStore] instance.
+func DefaultMemoryStore() MemoryStore {
+	defaultMemoryStoreMu.RLock()
+	defer defaultMemoryStoreMu.RUnlock()
+	return defaultMemoryStore
+}
+
+// SetDefaultMemoryStore sets the default instance of [MemoryStore] used to
+// save and load player memory. It resets to the [notSetMemoryStore] if nil is
+// provided.
+func SetDefaultMemoryStore(s MemoryStore) {
+	defaultMemoryStoreMu.Lock()
+	defer defaultMemoryStoreMu.Unlock()
+	if s == nil {
+		s = &notSetMemoryStore{}
+	}
+	defaultMemoryStore = s
+}
+
+// memoryStore returns the [MemoryStore] instance used to save and load the
+// player's memory. It falls back to [DefaultMemoryStore] if no custom one is
+// set via [Player.SetMemoryStore]. The caller must hold p.mu.
+func (p *Player) memoryStore() MemoryStore {
+	if p.customMemoryStore != nil {
+		return p.customMemoryStore
+	}
+	return DefaultMemoryStore()
+}
+
+// SetMemoryStore sets a custom [MemoryStore] for the player. It resets to
+// [DefaultMemoryStore] if nil is provided.
+func (p *Player) SetMemoryStore(s MemoryStore) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.customMemoryStore = s
+}
+
+// SaveMemory saves the player's memory under key, so it can be loaded via
+// [Player.LoadMemory] in a later game session. Errors are reported to the
+// handler registered via [Player.OnErr__0].
+func (p *Player) SaveMemory(key string) {
+	p.executeNative(func(ctx context.Context, owner any) {
+		if err := p.saveMemory(ctx, key); err != nil {
+			p.handleError(owner, err)
+		}
+	})
+}
+
+// saveMemory saves the player's memory under key.
+func (p *Player) saveMemory(ctx context.Context, key string) error {
+	data, err := p.Snapshot()
+	if err != nil {
+		return fmt.Errorf("failed to save memory %q: %w", key, err)
+	}
+	p.mu.RLock()
+	store := p.memoryStore()
+	p.mu.RUnlock()
+	if err := store.Save(ctx, key, data); err != nil {
+		return fmt.Errorf("failed to save memory %q: %w", key, err)
+	}
+	return nil
+}
+
+// LoadMemory replaces the player's memory with the one saved under key via
+// [Player.SaveMemory]. It does nothing if no memory is saved under key. Errors
+// are reported to the handler registered via [Player.OnErr__0].
+func (p *Player) LoadMemory(key string) {
+	p.executeNative(func(ctx context.Context, owner any) {
+		if err := p.loadMemory(ctx, key); err != nil {
+			p.handleError(owner, err)
+		}
+	})
+}
+
+// loadMemory replaces the player's memory with the one saved under key.
+func (p *Player) loadMemory(ctx context.Context, key string) error {
+	p.mu.RLock()
+	store := p.memoryStore()
+	p.mu.RUnlock()
+	data, err := store.Load(ctx, key)
+	if err != nil {
+		if errors.Is(err, ErrMemoryNotFound) {
+			return nil
+		}
+		return fmt.Errorf("failed to load memory %q: %w", key, err)
+	}
+	if err := p.Restore(data); err != nil {
+		return fmt.Errorf("failed to load memory %q: %w", key, err)
+	}
+	return nil
+}
@@ -0,0 +1,271 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mockMemoryStore is an in-memory [MemoryStore] for testing.
+type mockMemoryStore struct {
+	mu      sync.Mutex
+	entries map[string][]byte
+	err     error
+}
+
+// Load implements [MemoryStore].
+func (s *mockMemoryStore) Load(ctx context.Context, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return nil, s.err
+	}
+	data, ok := s.entries[key]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrMemoryNotFound, key)
+	}
+	return data, nil
+}
+
+// Save implements [MemoryStore].
+func (s *mockMemoryStore) Save(ctx context.Context, key string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	if s.entries == nil {
+		s.entries = make(map[string][]byte)
+	}
+	s.entries[key] = data
+	return nil
+}
+
+// Delete implements [MemoryStore].
+func (s *mockMemoryStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	delete(s.entries, key)
+	return nil
+}
+
+func TestPlayerSnapshotRestore(t *testing.T) {
+	p := &Player{}
+	p.SetRole__0("tutor", map[string]any{"subject": "math"})
+	p.history = []Turn{
+		{RequestContent: "What is 2+2?", IsInitial: true, ResponseCommandName: "Answer", ExecutedCommandResult: &CommandResult{Success: true}},
+		{RequestContent: "And 3+3?", IsInitial: true},
+	}
+	p.archivedHistory = "Learned addition."
+
+	data, err := p.Snapshot()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if got, want := raw["version"], float64(SnapshotVersion); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	restored := &Player{}
+	if err := restored.Restore(data); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if got, want := restored.role, "tutor"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := restored.roleContext, map[string]any{"subject": "math"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+	if got, want := restored.history, p.history; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+	if got, want := restored.archivedHistory, "Learned addition."; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestPlayerRestoreErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name          string
+		data          string
+		wantErrSubstr string
+	}{
+		{name: "InvalidJSON", data: "{", wantErrSubstr: "failed to unmarshal snapshot"},
+		{name: "MissingVersion", data: `{"role":"tutor"}`, wantErrSubstr: "unsupported snapshot version 0"},
+		{name: "FutureVersion", data: `{"version":2}`, wantErrSubstr: "unsupported snapshot version 2"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Player{}
+			p.SetRole__1("guide")
+			err := p.Restore([]byte(tt.data))
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if got, wantSubstr := err.Error(), tt.wantErrSubstr; !strings.Contains(got, wantSubstr) {
+				t.Errorf("got %q, want substring %q", got, wantSubstr)
+			}
+			if got, want := p.role, "guide"; got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestPlayerRestoreDuringArchive(t *testing.T) {
+	p := &Player{}
+	p.history = make([]Turn, 40)
+	p.history[0].IsInitial = true
+	p.history[20].IsInitial = true
+	turns, _ := p.prepareArchive(nil)
+	if got, want := len(turns), 20; got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+
+	if err := p.Restore([]byte(`{"version":1,"history":[{"content":"restored","isInitial":true}]}`)); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	p.applyArchive("stale archive", len(turns))
+
+	if got, want := len(p.history), 1; got != want {
+		t.Errorf("got %d, want %d", got, want)
+	}
+	if got, want := p.archivedHistory, ""; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if p.archiveInProgress || p.archiveDiscarded {
+		t.Error("expected archive flags to be reset")
+	}
+}
+
+func TestPlayerRestoreCancelsArchive(t *testing.T) {
+	started := make(chan struct{})
+	p := &Player{}
+	p.SetTransport(&mockTransport{
+		ArchiveFunc: func(ctx context.Context, turns []Turn, existingArchive string) (ArchivedHistory, error) {
+			close(started)
+			<-ctx.Done()
+			return ArchivedHistory{}, ctx.Err()
+		},
+	})
+	p.history = make([]Turn, 40)
+	p.history[0].IsInitial = true
+	p.history[20].IsInitial = true
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.manageHistory(context.Background(), nil)
+	}()
+	<-started
+	if err := p.Restore([]byte(`{"version":1,"history":[{"content":"restored","isInitial":true}]}`)); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("archive was not canceled")
+	}
+
+	if got, want := len(p.history), 1; got != want {
+		t.Errorf("got %d, want %d", got, want)
+	}
+	if got, want := p.archivedHistory, ""; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if p.archiveInProgress || p.archiveDiscarded || p.archiveCancel != nil {
+		t.Error("expected archive state to be reset")
+	}
+}
+
+func TestPlayerSetMemoryStore(t *testing.T) {
+	originalStore := DefaultMemoryStore()
+	t.Cleanup(func() { SetDefaultMemoryStore(originalStore) })
+
+	p := &Player{}
+	if _, err := p.memoryStore().Load(context.Background(), "key"); !errors.Is(err, ErrMemoryStoreNotSet) {
+		t.Errorf("got %v, want %v", err, ErrMemoryStoreNotSet)
+	}
+
+	defaultStore := &mockMemoryStore{}
+	SetDefaultMemoryStore(defaultStore)
+	if got, want := p.memoryStore(), MemoryStore(defaultStore); got != want {
+		t.Errorf("got %p, want %p", got, want)
+	}
+
+	customStore := &mockMemoryStore{}
+	p.SetMemoryStore(customStore)
+	if got, want := p.memoryStore(), MemoryStore(customStore); got != want {
+		t.Errorf("got %p, want %p", got, want)
+	}
+
+	p.SetMemoryStore(nil)
+	if got, want := p.memoryStore(), MemoryStore(defaultStore); got != want {
+		t.Errorf("got %p, want %p", got, want)
+	}
+}
+
+func TestPlayerSaveLoadMemory(t *testing.T) {
+	store := &mockMemoryStore{}
+
+	p := &Player{}
+	p.SetMemoryStore(store)
+	p.SetRole__1("tutor")
+	p.history = []Turn{{RequestContent: "Teach me fractions", IsInitial: true}}
+	p.SaveMemory("tutor")
+
+	loaded := &Player{}
+	loaded.SetMemoryStore(store)
+	loaded.LoadMemory("tutor")
+	if got, want := loaded.role, "tutor"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := loaded.history, p.history; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+
+	t.Run("NotFound", func(t *testing.T) {
+		var gotErr error
+		p := &Player{}
+		p.SetMemoryStore(store)
+		p.SetRole__1("guide")
+		p.OnErr__0(func(err error) { gotErr = err })
+		p.LoadMemory("unknown")
+		if gotErr != nil {
+			t.Errorf("unexpected error %v", gotErr)
+		}
+		if got, want := p.role, "guide"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("StoreError", func(t *testing.T) {
+		storeErr := errors.New("disk full")
+		var gotErrs []error
+		p := &Player{}
+		p.SetMemoryStore(&mockMemoryStore{err: storeErr})
+		p.OnErr__0(func(err error) { gotErrs = append(gotErrs, err) })
+		p.SaveMemory("tutor")
+		p.LoadMemory("tutor")
+		if got, want := len(gotErrs), 2; got != want {
+			t.Fatalf("got %d, want %d", got, want)
+		}
+		for _, err := range gotErrs {
+			if !errors.Is(err, storeErr) {
+				t.Errorf("got %v, want %v", err, storeErr)
+			}
+		}
+	})
+}
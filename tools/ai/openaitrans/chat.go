@@ -0,0 +1,279 @@
+package openaitrans
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/goplus/builder/tools/ai"
+)
+
+// chatRequest is the body of a chat completions request.
+type chatRequest struct {
+	Model       string        `json:"model"`
+	Messages    []chatMessage `json:"messages"`
+	Tools       []chatTool    `json:"tools,omitempty"`
+	Temperature *float64      `json:"temperature,omitempty"`
+}
+
+// chatResponse is the body of a chat completions response.
+type chatResponse struct {
+	Choices []struct {
+		Message chatMessage `json:"message"`
+	} `json:"choices"`
+}
+
+// chatMessage is a message of a chat completions conversation.
+type chatMessage struct {
+	Role       string         `json:"role"`
+	Content    string         `json:"content,omitempty"`
+	ToolCalls  []chatToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string         `json:"tool_call_id,omitempty"`
+}
+
+// chatTool is a tool definition of a chat completions request.
+type chatTool struct {
+	Type     string           `json:"type"`
+	Function chatToolFunction `json:"function"`
+}
+
+// chatToolFunction describes the function of a [chatTool].
+type chatToolFunction struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Parameters  map[string]any `json:"parameters"`
+}
+
+// chatToolCall is a tool call requested in an assistant message.
+type chatToolCall struct {
+	ID       string               `json:"id"`
+	Type     string               `json:"type"`
+	Function chatToolCallFunction `json:"function"`
+}
+
+// chatToolCallFunction describes the function call of a [chatToolCall].
+type chatToolCallFunction struct {
+	Name string `json:"name"`
+
+	// Arguments is the JSON-encoded arguments object.
+	Arguments string `json:"arguments"`
+}
+
+const (
+	roleSystem    = "system"
+	roleUser      = "user"
+	roleAssistant = "assistant"
+	roleTool      = "tool"
+)
+
+// instructions is the beginning of the system message of every interaction.
+const instructions = `You are an AI player in a game made by a child. Stay in your role and keep your replies short and friendly.
+
+Act in the game by calling the provided tools. After each call, you receive its result and can call more tools based on it. When you have nothing more to do, reply with text only and no tool calls to end your turn.`
+
+// notExecutedResult is the tool result of a command that was not executed
+// because an earlier command in the same response ended the turn.
+const notExecutedResult = `{"success":false,"errorMessage":"not executed because an earlier command failed or ended the interaction"}`
+
+// buildMessages turns req into the messages of a chat completions
+// conversation.
+func buildMessages(req ai.Request) []chatMessage {
+	messages := []chatMessage{{Role: roleSystem, Content: buildSystemPrompt(req)}}
+	for i, turn := range req.History {
+		messages = append(messages, turnMessages(i, turn)...)
+	}
+	if req.Content != "" || req.Context != nil {
+		messages = append(messages, chatMessage{
+			Role:    roleUser,
+			Content: userContent(req.Content, req.Context),
+		})
+	}
+	return messages
+}
+
+// buildSystemPrompt returns the system message of req, describing the role,
+// knowledge base and archived history.
+func buildSystemPrompt(req ai.Request) string {
+	var sb strings.Builder
+	sb.WriteString(instructions)
+	if req.Role != "" || req.RoleContext != nil {
+		sb.WriteString("\n\n## Your role\n\n")
+		sb.WriteString(req.Role)
+		if req.RoleContext != nil {
+			sb.WriteString("\n\nRole context: ")
+			sb.WriteString(marshalString(req.RoleContext))
+		}
+	}
+	if len(req.KnowledgeBase) > 0 {
+		sb.WriteString("\n\n## Knowledge base\n\n")
+		sb.WriteString(marshalString(req.KnowledgeBase))
+	}
+	if req.ArchivedHistory != "" {
+		sb.WriteString("\n\n## Summary of earlier interactions\n\n")
+		sb.WriteString(req.ArchivedHistory)
+	}
+	return sb.String()
+}
+
+// turnMessages turns the i-th history turn into messages. Tool call IDs are
+// derived from i, as they are not kept in the history.
+func turnMessages(i int, turn ai.Turn) []chatMessage {
+	var messages []chatMessage
+	if turn.RequestContent != "" || turn.RequestContext != nil {
+		messages = append(messages, chatMessage{
+			Role:    roleUser,
+			Content: userContent(turn.RequestContent, turn.RequestContext),
+		})
+	}
+	if turn.IsInterrupted {
+		return append(messages, chatMessage{
+			Role:    roleAssistant,
+			Content: "(The game interrupted this interaction before I replied.)",
+		})
+	}
+
+	calls, results := turnCommands(turn)
+	assistant := chatMessage{Role: roleAssistant, Content: turn.ResponseText}
+	for j, call := range calls {
+		args := call.Args
+		if args == nil {
+			args = map[string]any{}
+		}
+		assistant.ToolCalls = append(assistant.ToolCalls, chatToolCall{
+			ID:   fmt.Sprintf("call_%d_%d", i, j),
+			Type: "function",
+			Function: chatToolCallFunction{
+				Name:      call.Name,
+				Arguments: marshalString(args),
+			},
+		})
+	}
+	if assistant.Content == "" && len(assistant.ToolCalls) == 0 {
+		// An assistant message needs content or tool calls, so skip the
+		// reply that had neither.
+		return messages
+	}
+	messages = append(messages, assistant)
+	for j, tc := range assistant.ToolCalls {
+		content := notExecutedResult
+		if j < len(results) && results[j] != nil {
+			content = marshalString(results[j])
+		}
+		messages = append(messages, chatMessage{
+			Role:       roleTool,
+			Content:    content,
+			ToolCallID: tc.ID,
+		})
+	}
+	return messages
+}
+
+// turnCommands returns the commands requested in turn and the results of the
+// executed ones.
+func turnCommands(turn ai.Turn) ([]ai.CommandCall, []*ai.CommandResult) {
+	if len(turn.ResponseCommandCalls) > 0 {
+		return turn.ResponseCommandCalls, turn.ExecutedCommandResults
+	}
+	if turn.ResponseCommandName != "" {
+		return []ai.CommandCall{{Name: turn.ResponseCommandName, Args: turn.ResponseCommandArgs}},
+			[]*ai.CommandResult{turn.ExecutedCommandResult}
+	}
+	return nil, nil
+}
+
+// userContent returns the content of a user message with content and its
+// context.
+func userContent(content string, context map[string]any) string {
+	if context == nil {
+		return content
+	}
+	return content + "\n\nContext: " + marshalString(context)
+}
+
+// buildTools turns specs into tool definitions, sorted by name so requests
+// are stable.
+func buildTools(specs []ai.CommandSpec) []chatTool {
+	if len(specs) == 0 {
+		return nil
+	}
+	tools := make([]chatTool, 0, len(specs))
+	for _, spec := range specs {
+		tools = append(tools, chatTool{
+			Type: "function",
+			Function: chatToolFunction{
+				Name:        spec.Name,
+				Description: spec.Description,
+				Parameters:  spec.ParametersSchema(),
+			},
+		})
+	}
+	slices.SortFunc(tools, func(a, b chatTool) int {
+		return cmp.Compare(a.Function.Name, b.Function.Name)
+	})
+	return tools
+}
+
+// parseResponse turns an assistant message into an [ai.Response]. A single
+// tool call becomes [ai.Response.CommandName] and
+// [ai.Response.CommandArgs], while several become
+// [ai.Response.CommandCalls].
+func parseResponse(msg chatMessage) (ai.Response, error) {
+	resp := ai.Response{Text: msg.Content}
+	calls := make([]ai.CommandCall, 0, len(msg.ToolCalls))
+	for _, tc := range msg.ToolCalls {
+		var args map[string]any
+		if arguments := strings.TrimSpace(tc.Function.Arguments); arguments != "" {
+			if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+				return ai.Response{}, fmt.Errorf("%w: failed to unmarshal arguments of tool call %s: %w", ai.ErrInvalidResponse, tc.Function.Name, err)
+			}
+		}
+		calls = append(calls, ai.CommandCall{Name: tc.Function.Name, Args: args})
+	}
+	switch len(calls) {
+	case 0:
+	case 1:
+		resp.CommandName = calls[0].Name
+		resp.CommandArgs = calls[0].Args
+	default:
+		resp.CommandCalls = calls
+	}
+	return resp, nil
+}
+
+// archiveInstructions is the system message of archive requests.
+const archiveInstructions = `You summarize the history of interactions between a game and an AI player, so the player remembers what matters after the history is dropped.
+
+Write a concise summary in plain text of the important facts, decisions, game state and commands with their results. If there is an existing summary, merge the new interactions into it. Reply with the summary only.`
+
+// buildArchiveMessages returns the messages asking the model to summarize
+// turns into existingArchive.
+func buildArchiveMessages(turns []ai.Turn, existingArchive string) ([]chatMessage, error) {
+	turnsJSON, err := json.MarshalIndent(turns, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal archive request: %w", err)
+	}
+	var sb strings.Builder
+	if existingArchive != "" {
+		sb.WriteString("Existing summary:\n\n")
+		sb.WriteString(existingArchive)
+		sb.WriteString("\n\n")
+	}
+	sb.WriteString("Interactions to summarize:\n\n")
+	sb.Write(turnsJSON)
+	return []chatMessage{
+		{Role: roleSystem, Content: archiveInstructions},
+		{Role: roleUser, Content: sb.String()},
+	}, nil
+}
+
+// marshalString returns the JSON encoding of v as a string. Values that
+// cannot be encoded are formatted with fmt instead.
+func marshalString(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
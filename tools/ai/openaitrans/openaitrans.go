@@ -0,0 +1,170 @@
+// Package openaitrans provides a Transport implementation for AI interactions
+// that talks directly to an OpenAI-compatible chat completions API, such as
+// the ones served by llama.cpp or Ollama, so games can use self-hosted models
+// without the XBuilder backend.
+package openaitrans
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/goplus/builder/tools/ai"
+	"github.com/goplus/builder/tools/ai/internal/httpstatus"
+)
+
+// openaiTransport implements [ai.Transport] using an OpenAI-compatible chat
+// completions API.
+type openaiTransport struct {
+	// client is the HTTP client used for making requests.
+	client *http.Client
+
+	// endpoint is the URL for the chat completions API.
+	endpoint string
+
+	// model is the name of the model to use.
+	model string
+
+	// apiKey is the API key sent as the Bearer token. If empty, no auth
+	// header is sent.
+	apiKey string
+
+	// temperature is the sampling temperature. If nil, the server default is
+	// used.
+	temperature *float64
+}
+
+// Option is a function type for configuring the [openaiTransport].
+type Option func(*openaiTransport)
+
+// WithHTTPClient sets a custom HTTP client for making requests. If not set,
+// [http.DefaultClient] will be used.
+func WithHTTPClient(client *http.Client) Option {
+	return func(t *openaiTransport) {
+		t.client = client
+	}
+}
+
+// WithAPIKey sets the API key sent as the Bearer token for Authorization.
+// Local servers usually don't need one.
+func WithAPIKey(apiKey string) Option {
+	return func(t *openaiTransport) {
+		t.apiKey = apiKey
+	}
+}
+
+// WithTemperature sets the sampling temperature. If not set, the server
+// default is used.
+func WithTemperature(temperature float64) Option {
+	return func(t *openaiTransport) {
+		t.temperature = &temperature
+	}
+}
+
+// New creates a new [ai.Transport] that sends requests to the chat
+// completions API under baseURL (e.g., "http://localhost:11434/v1" for
+// Ollama) using model.
+//
+// Each [ai.Request] is turned into a chat completions request: the role,
+// knowledge base and archived history go into the system message, the history
+// becomes the conversation, and the command specs become tool definitions.
+// Tool calls in the reply are turned back into commands. Archive asks the
+// model to summarize the turns.
+func New(baseURL, model string, opts ...Option) ai.Transport {
+	t := &openaiTransport{
+		client:   http.DefaultClient,
+		endpoint: strings.TrimSuffix(baseURL, "/") + "/chat/completions",
+		model:    model,
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// Interact implements [ai.Transport].
+func (t *openaiTransport) Interact(ctx context.Context, req ai.Request) (ai.Response, error) {
+	msg, err := t.complete(ctx, chatRequest{
+		Messages: buildMessages(req),
+		Tools:    buildTools(req.CommandSpecs),
+	})
+	if err != nil {
+		return ai.Response{}, err
+	}
+	return parseResponse(msg)
+}
+
+// Archive implements [ai.Transport].
+func (t *openaiTransport) Archive(ctx context.Context, turns []ai.Turn, existingArchive string) (ai.ArchivedHistory, error) {
+	messages, err := buildArchiveMessages(turns, existingArchive)
+	if err != nil {
+		return ai.ArchivedHistory{}, err
+	}
+	msg, err := t.complete(ctx, chatRequest{Messages: messages})
+	if err != nil {
+		return ai.ArchivedHistory{}, err
+	}
+	content := strings.TrimSpace(msg.Content)
+	if content == "" {
+		return ai.ArchivedHistory{}, fmt.Errorf("%w: empty summary", ai.ErrInvalidResponse)
+	}
+	return ai.ArchivedHistory{Content: content}, nil
+}
+
+// complete sends a chat completions request and returns the message of its
+// first choice.
+func (t *openaiTransport) complete(ctx context.Context, creq chatRequest) (chatMessage, error) {
+	creq.Model = t.model
+	creq.Temperature = t.temperature
+	reqBody, err := json.Marshal(creq)
+	if err != nil {
+		return chatMessage{}, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", t.endpoint, bytes.NewReader(reqBody))
+	if err != nil {
+		return chatMessage{}, fmt.Errorf("failed to create http request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if t.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+t.apiKey)
+	}
+
+	httpResp, err := t.client.Do(httpReq)
+	if err != nil {
+		return chatMessage{}, fmt.Errorf("failed to execute http request: %w", err)
+	}
+
+	var cresp chatResponse
+	if err := handleResponse(httpResp, &cresp); err != nil {
+		return chatMessage{}, err
+	}
+	if len(cresp.Choices) == 0 {
+		return chatMessage{}, fmt.Errorf("%w: no choices in response", ai.ErrInvalidResponse)
+	}
+	return cresp.Choices[0].Message, nil
+}
+
+// handleResponse processes an HTTP response and unmarshals it into the target.
+func handleResponse(resp *http.Response, target any) error {
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read http response body: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return httpstatus.Error(resp, body)
+	}
+
+	if err := json.Unmarshal(body, target); err != nil {
+		return fmt.Errorf("%w: failed to unmarshal response json: %w", ai.ErrInvalidResponse, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,345 @@
+package openaitrans
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goplus/builder/tools/ai"
+)
+
+// newServer starts a test server that records the chat completions requests
+// it receives and responds with respond.
+func newServer(t *testing.T, respond func(w http.ResponseWriter, req chatRequest)) (*httptest.Server, *[]*http.Request, *[]chatRequest) {
+	t.Helper()
+	var (
+		httpReqs []*http.Request
+		reqs     []chatRequest
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req chatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		httpReqs = append(httpReqs, r)
+		reqs = append(reqs, req)
+		respond(w, req)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &httpReqs, &reqs
+}
+
+// writeMessage writes a chat completions response with msg.
+func writeMessage(w http.ResponseWriter, msg chatMessage) {
+	json.NewEncoder(w).Encode(map[string]any{
+		"choices": []any{map[string]any{"message": msg}},
+	})
+}
+
+func TestInteract(t *testing.T) {
+	srv, httpReqs, reqs := newServer(t, func(w http.ResponseWriter, req chatRequest) {
+		writeMessage(w, chatMessage{
+			Role:    roleAssistant,
+			Content: "I'll move.",
+			ToolCalls: []chatToolCall{{
+				ID:       "call_1",
+				Type:     "function",
+				Function: chatToolCallFunction{Name: "Move", Arguments: `{"Steps":2}`},
+			}},
+		})
+	})
+	transport := New(srv.URL+"/v1/", "llama3", WithAPIKey("secret"), WithTemperature(0.5))
+
+	resp, err := transport.Interact(context.Background(), ai.Request{
+		Content:         "Go!",
+		Context:         map[string]any{"Score": 3},
+		Role:            "a brave knight",
+		RoleContext:     map[string]any{"Name": "Arthur"},
+		KnowledgeBase:   map[string]any{"Rules": "Reach the castle."},
+		ArchivedHistory: "We met a dragon.",
+		CommandSpecs: []ai.CommandSpec{
+			{Name: "Wait", Description: "Wait a moment."},
+			{Name: "Move", Description: "Move forward.", Parameters: []ai.CommandParamSpec{
+				{Name: "Steps", Type: "int", Required: true, Schema: map[string]any{"type": "integer"}},
+			}},
+		},
+		History: []ai.Turn{
+			{
+				RequestContent:        "Hello",
+				ResponseText:          "Hi!",
+				ResponseCommandName:   "Wait",
+				ExecutedCommandResult: &ai.CommandResult{Success: true},
+				IsInitial:             true,
+			},
+			{
+				ResponseText: "Done waiting.",
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got, want := resp, (ai.Response{
+		Text:        "I'll move.",
+		CommandName: "Move",
+		CommandArgs: map[string]any{"Steps": 2.0},
+	}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+
+	if got, want := len(*reqs), 1; got != want {
+		t.Fatalf("got %d requests, want %d", got, want)
+	}
+	httpReq, req := (*httpReqs)[0], (*reqs)[0]
+	if got, want := httpReq.URL.Path, "/v1/chat/completions"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := httpReq.Header.Get("Authorization"), "Bearer secret"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := req.Model, "llama3"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if req.Temperature == nil || *req.Temperature != 0.5 {
+		t.Errorf("got %v, want 0.5", req.Temperature)
+	}
+
+	var toolNames []string
+	for _, tool := range req.Tools {
+		toolNames = append(toolNames, tool.Function.Name)
+	}
+	if got, want := toolNames, []string{"Move", "Wait"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := req.Tools[0].Function.Parameters["required"], []any{"Steps"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	system := req.Messages[0]
+	if got, want := system.Role, roleSystem; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	for _, want := range []string{"a brave knight", `{"Name":"Arthur"}`, "Reach the castle.", "We met a dragon."} {
+		if !strings.Contains(system.Content, want) {
+			t.Errorf("system message %q does not contain %q", system.Content, want)
+		}
+	}
+
+	var roles []string
+	for _, msg := range req.Messages[1:] {
+		roles = append(roles, msg.Role)
+	}
+	if got, want := roles, []string{roleUser, roleAssistant, roleTool, roleAssistant, roleUser}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	assistant, tool := req.Messages[2], req.Messages[3]
+	if got, want := len(assistant.ToolCalls), 1; got != want {
+		t.Fatalf("got %d tool calls, want %d", got, want)
+	}
+	if got, want := assistant.ToolCalls[0].Function.Arguments, "{}"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := tool.ToolCallID, assistant.ToolCalls[0].ID; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := tool.Content, `{"success":true}`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := req.Messages[5].Content, "Go!\n\nContext: {\"Score\":3}"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestTurnMessages(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		turn      ai.Turn
+		wantRoles []string
+	}{
+		{
+			name:      "TextOnly",
+			turn:      ai.Turn{RequestContent: "Hello", ResponseText: "Hi!"},
+			wantRoles: []string{roleUser, roleAssistant},
+		},
+		{
+			name:      "EmptyResponse",
+			turn:      ai.Turn{RequestContent: "Hello"},
+			wantRoles: []string{roleUser},
+		},
+		{
+			name:      "EmptyContinuation",
+			turn:      ai.Turn{},
+			wantRoles: nil,
+		},
+		{
+			name:      "CommandOnly",
+			turn:      ai.Turn{ResponseCommandName: "Wait"},
+			wantRoles: []string{roleAssistant, roleTool},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotRoles []string
+			for _, msg := range turnMessages(0, tt.turn) {
+				gotRoles = append(gotRoles, msg.Role)
+			}
+			if got, want := gotRoles, tt.wantRoles; !reflect.DeepEqual(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestInteractResponse(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		msg     chatMessage
+		want    ai.Response
+		wantErr error
+	}{
+		{
+			name: "TextOnly",
+			msg:  chatMessage{Role: roleAssistant, Content: "Bye!"},
+			want: ai.Response{Text: "Bye!"},
+		},
+		{
+			name: "MultipleToolCalls",
+			msg: chatMessage{Role: roleAssistant, ToolCalls: []chatToolCall{
+				{ID: "a", Type: "function", Function: chatToolCallFunction{Name: "Move", Arguments: `{"Steps":1}`}},
+				{ID: "b", Type: "function", Function: chatToolCallFunction{Name: "Wait"}},
+			}},
+			want: ai.Response{CommandCalls: []ai.CommandCall{
+				{Name: "Move", Args: map[string]any{"Steps": 1.0}},
+				{Name: "Wait"},
+			}},
+		},
+		{
+			name: "InvalidArguments",
+			msg: chatMessage{Role: roleAssistant, ToolCalls: []chatToolCall{
+				{ID: "a", Type: "function", Function: chatToolCallFunction{Name: "Move", Arguments: `{"Steps":`}},
+			}},
+			wantErr: ai.ErrInvalidResponse,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, _, _ := newServer(t, func(w http.ResponseWriter, req chatRequest) {
+				writeMessage(w, tt.msg)
+			})
+			transport := New(srv.URL, "model")
+
+			resp, err := transport.Interact(context.Background(), ai.Request{Content: "Go!"})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("got %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(resp, tt.want) {
+				t.Errorf("got %#v, want %#v", resp, tt.want)
+			}
+		})
+	}
+}
+
+func TestInteractError(t *testing.T) {
+	t.Run("TooManyRequests", func(t *testing.T) {
+		srv, _, _ := newServer(t, func(w http.ResponseWriter, req chatRequest) {
+			w.Header().Set("Retry-After", "3")
+			w.WriteHeader(http.StatusTooManyRequests)
+		})
+
+		_, err := New(srv.URL, "model").Interact(context.Background(), ai.Request{Content: "Go!"})
+
+		var tmrErr *ai.TooManyRequestsError
+		if !errors.As(err, &tmrErr) {
+			t.Fatalf("got %v, want a TooManyRequestsError", err)
+		}
+		if got, want := tmrErr.RetryAfter, 3*time.Second; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("ForbiddenIsNotQuota", func(t *testing.T) {
+		srv, _, _ := newServer(t, func(w http.ResponseWriter, req chatRequest) {
+			w.Header().Set("Retry-After", "3")
+			http.Error(w, "invalid api key", http.StatusForbidden)
+		})
+
+		_, err := New(srv.URL, "model").Interact(context.Background(), ai.Request{Content: "Go!"})
+
+		if ai.IsQuotaExceeded(err) {
+			t.Errorf("got %v, want no quota exceeded error", err)
+		}
+		var statusErr *ai.StatusError
+		if !errors.As(err, &statusErr) {
+			t.Fatalf("got %v, want a StatusError", err)
+		}
+	})
+
+	t.Run("ServerError", func(t *testing.T) {
+		srv, _, _ := newServer(t, func(w http.ResponseWriter, req chatRequest) {
+			http.Error(w, "model not loaded", http.StatusInternalServerError)
+		})
+
+		_, err := New(srv.URL, "model").Interact(context.Background(), ai.Request{Content: "Go!"})
+
+		var statusErr *ai.StatusError
+		if !errors.As(err, &statusErr) {
+			t.Fatalf("got %v, want a StatusError", err)
+		}
+		if got, want := statusErr.StatusCode, http.StatusInternalServerError; got != want {
+			t.Errorf("got %d, want %d", got, want)
+		}
+	})
+
+	t.Run("NoChoices", func(t *testing.T) {
+		srv, _, _ := newServer(t, func(w http.ResponseWriter, req chatRequest) {
+			w.Write([]byte(`{"choices":[]}`))
+		})
+
+		_, err := New(srv.URL, "model").Interact(context.Background(), ai.Request{Content: "Go!"})
+
+		if !errors.Is(err, ai.ErrInvalidResponse) {
+			t.Errorf("got %v, want %v", err, ai.ErrInvalidResponse)
+		}
+	})
+}
+
+func TestArchive(t *testing.T) {
+	srv, _, reqs := newServer(t, func(w http.ResponseWriter, req chatRequest) {
+		writeMessage(w, chatMessage{Role: roleAssistant, Content: "  The knight met a dragon and said hello.\n"})
+	})
+	transport := New(srv.URL, "model")
+
+	archived, err := transport.Archive(context.Background(), []ai.Turn{
+		{RequestContent: "Hello", ResponseText: "Hi!"},
+	}, "The knight met a dragon.")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got, want := archived.Content, "The knight met a dragon and said hello."; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	req := (*reqs)[0]
+	if got, want := len(req.Tools), 0; got != want {
+		t.Errorf("got %d tools, want %d", got, want)
+	}
+	if got, want := len(req.Messages), 2; got != want {
+		t.Fatalf("got %d messages, want %d", got, want)
+	}
+	for _, want := range []string{"The knight met a dragon.", `"requestContent": "Hello"`} {
+		if !strings.Contains(req.Messages[1].Content, want) {
+			t.Errorf("message %q does not contain %q", req.Messages[1].Content, want)
+		}
+	}
+}
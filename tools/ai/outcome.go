@@ -0,0 +1,64 @@
+package ai
+
+// EndReason describes why an interaction sequence ended.
+type EndReason int
+
+const (
+	// EndReasonError indicates the sequence ended due to an error, which is
+	// available in [Outcome.Err].
+	EndReasonError EndReason = iota
+
+	// EndReasonNoCommand indicates the AI signaled completion by responding
+	// without a command.
+	EndReasonNoCommand
+
+	// EndReasonBreak indicates a command handler returned [Break].
+	EndReasonBreak
+
+	// EndReasonMaxTurns indicates the sequence reached the maximum number of
+	// turns allowed by [InteractionPolicy.MaxTurns]. [Outcome.Err] is
+	// [ErrMaxTurnsExceeded] in this case.
+	EndReasonMaxTurns
+)
+
+// String implements [fmt.Stringer].
+func (r EndReason) String() string {
+	switch r {
+	case EndReasonError:
+		return "error"
+	case EndReasonNoCommand:
+		return "no command"
+	case EndReasonBreak:
+		return "break"
+	case EndReasonMaxTurns:
+		return "max turns"
+	}
+	return "unknown"
+}
+
+// ExecutedCommand records a command executed during an interaction sequence.
+type ExecutedCommand struct {
+	// Name is the name of the executed command.
+	Name string
+
+	// Args holds the arguments the AI provided for the command.
+	Args map[string]any
+
+	// Result is the outcome of executing the command.
+	Result *CommandResult
+}
+
+// Outcome describes the final outcome of an interaction sequence.
+type Outcome struct {
+	// Text is the textual part of the last AI response.
+	Text string
+
+	// Commands lists the commands executed during the sequence, in order.
+	Commands []ExecutedCommand
+
+	// EndReason describes why the sequence ended.
+	EndReason EndReason
+
+	// Err is the error that ended the sequence, if any.
+	Err error
+}
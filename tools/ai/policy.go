@@ -0,0 +1,139 @@
+package ai
+
+import (
+	"sync"
+	"time"
+)
+
+// InteractionPolicy controls timeouts, retries and limits of AI interactions.
+//
+// A zero field means "not set", in which case the value is inherited from
+// [DefaultInteractionPolicy] for a [Player], or from the built-in default for
+// the default policy itself. So a zero-value InteractionPolicy inherits
+// everything. Negative fields are invalid and treated as not set too.
+type InteractionPolicy struct {
+	// TransportTimeout is the timeout for each [Transport.Interact] call.
+	TransportTimeout time.Duration
+
+	// MaxTransportAttempts is the maximum number of [Transport.Interact]
+	// attempts per turn.
+	MaxTransportAttempts int
+
+	// MaxTurns is the maximum number of turns in a single interaction
+	// sequence, preventing infinite loops.
+	MaxTurns int
+
+	// BackoffBase is the base time for exponential backoff between
+	// [Transport.Interact] attempts.
+	BackoffBase time.Duration
+
+	// BackoffCap is the maximum backoff time between [Transport.Interact]
+	// attempts.
+	BackoffCap time.Duration
+
+	// RateLimitWaitTimeout is the maximum time to wait for the backend to
+	// allow another attempt after being rate limited.
+	RateLimitWaitTimeout time.Duration
+
+	// ArchiveTimeout is the timeout for each [Transport.Archive] call.
+	ArchiveTimeout time.Duration
+
+	// MaxArchiveAttempts is the maximum number of [Transport.Archive]
+	// attempts per archive operation.
+	MaxArchiveAttempts int
+
+	// ArchiveBackoffBase is the base time for exponential backoff between
+	// [Transport.Archive] attempts.
+	ArchiveBackoffBase time.Duration
+
+	// ArchiveBackoffCap is the maximum backoff time between
+	// [Transport.Archive] attempts.
+	ArchiveBackoffCap time.Duration
+
+	// ArchiveThreshold is the number of history turns that triggers archiving.
+	ArchiveThreshold int
+
+	// ArchiveMinRetained is the minimum number of most recent history turns
+	// kept after archiving.
+	ArchiveMinRetained int
+
+	// ArchiveByteThreshold is the total size in bytes of the JSON-encoded
+	// history turns that triggers archiving, regardless of the number of
+	// turns. When triggered by size, fewer than ArchiveMinRetained turns may
+	// be kept so that the kept turns fit in half of this size.
+	ArchiveByteThreshold int
+
+	// RequestTokenBudget is the maximum estimated number of tokens of each
+	// [Request], as counted by the player's [Tokenizer]. Older interaction
+	// sequences in [Request.History] are left out to fit the budget.
+	RequestTokenBudget int
+}
+
+// builtinInteractionPolicy is the built-in default [InteractionPolicy].
+var builtinInteractionPolicy = InteractionPolicy{
+	TransportTimeout:     45 * time.Second,
+	MaxTransportAttempts: 3,
+	MaxTurns:             20,
+	BackoffBase:          100 * time.Millisecond,
+	BackoffCap:           2 * time.Second,
+	RateLimitWaitTimeout: 2 * time.Minute,
+	ArchiveTimeout:       120 * time.Second,
+	MaxArchiveAttempts:   3,
+	ArchiveBackoffBase:   500 * time.Millisecond,
+	ArchiveBackoffCap:    5 * time.Second,
+	ArchiveThreshold:     30,
+	ArchiveMinRetained:   15,
+	ArchiveByteThreshold: 64 * 1024,
+	RequestTokenBudget:   32 * 1024,
+}
+
+// withFallback returns a copy of ip with all zero or negative fields replaced
+// by the corresponding fields of fallback.
+func (ip InteractionPolicy) withFallback(fallback InteractionPolicy) InteractionPolicy {
+	ip.TransportTimeout = orFallback(ip.TransportTimeout, fallback.TransportTimeout)
+	ip.MaxTransportAttempts = orFallback(ip.MaxTransportAttempts, fallback.MaxTransportAttempts)
+	ip.MaxTurns = orFallback(ip.MaxTurns, fallback.MaxTurns)
+	ip.BackoffBase = orFallback(ip.BackoffBase, fallback.BackoffBase)
+	ip.BackoffCap = orFallback(ip.BackoffCap, fallback.BackoffCap)
+	ip.RateLimitWaitTimeout = orFallback(ip.RateLimitWaitTimeout, fallback.RateLimitWaitTimeout)
+	ip.ArchiveTimeout = orFallback(ip.ArchiveTimeout, fallback.ArchiveTimeout)
+	ip.MaxArchiveAttempts = orFallback(ip.MaxArchiveAttempts, fallback.MaxArchiveAttempts)
+	ip.ArchiveBackoffBase = orFallback(ip.ArchiveBackoffBase, fallback.ArchiveBackoffBase)
+	ip.ArchiveBackoffCap = orFallback(ip.ArchiveBackoffCap, fallback.ArchiveBackoffCap)
+	ip.ArchiveThreshold = orFallback(ip.ArchiveThreshold, fallback.ArchiveThreshold)
+	ip.ArchiveMinRetained = orFallback(ip.ArchiveMinRetained, fallback.ArchiveMinRetained)
+	ip.ArchiveByteThreshold = orFallback(ip.ArchiveByteThreshold, fallback.ArchiveByteThreshold)
+	ip.RequestTokenBudget = orFallback(ip.RequestTokenBudget, fallback.RequestTokenBudget)
+	return ip
+}
+
+// orFallback returns v if it is positive, or fallback otherwise.
+func orFallback[T int | time.Duration](v, fallback T) T {
+	if v > 0 {
+		return v
+	}
+	return fallback
+}
+
+var (
+	// defaultInteractionPolicy is the default [InteractionPolicy] for AI players.
+	defaultInteractionPolicy   InteractionPolicy
+	defaultInteractionPolicyMu sync.RWMutex
+)
+
+// DefaultInteractionPolicy returns the default [InteractionPolicy] for AI
+// players, with all unset fields filled by the built-in defaults.
+func DefaultInteractionPolicy() InteractionPolicy {
+	defaultInteractionPolicyMu.RLock()
+	defer defaultInteractionPolicyMu.RUnlock()
+	return defaultInteractionPolicy.withFallback(builtinInteractionPolicy)
+}
+
+// SetDefaultInteractionPolicy sets the default [InteractionPolicy] for AI
+// players. Unset fields, i.e., zero or negative ones, fall back to the
+// built-in defaults.
+func SetDefaultInteractionPolicy(policy InteractionPolicy) {
+	defaultInteractionPolicyMu.Lock()
+	defer defaultInteractionPolicyMu.Unlock()
+	defaultInteractionPolicy = policy
+}
@@ -0,0 +1,85 @@
+package ai
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInteractionPolicyWithFallback(t *testing.T) {
+	policy := InteractionPolicy{
+		TransportTimeout:     5 * time.Second,
+		MaxTransportAttempts: 2,
+	}.withFallback(builtinInteractionPolicy)
+
+	if got, want := policy.TransportTimeout, 5*time.Second; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := policy.MaxTransportAttempts, 2; got != want {
+		t.Errorf("got %d, want %d", got, want)
+	}
+	if got, want := policy.MaxTurns, builtinInteractionPolicy.MaxTurns; got != want {
+		t.Errorf("got %d, want %d", got, want)
+	}
+	if got, want := policy.ArchiveThreshold, builtinInteractionPolicy.ArchiveThreshold; got != want {
+		t.Errorf("got %d, want %d", got, want)
+	}
+
+	if got, want := (InteractionPolicy{}).withFallback(builtinInteractionPolicy), builtinInteractionPolicy; got != want {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+
+	negative := InteractionPolicy{
+		TransportTimeout: -time.Second,
+		MaxTurns:         -1,
+	}.withFallback(builtinInteractionPolicy)
+	if got, want := negative.TransportTimeout, builtinInteractionPolicy.TransportTimeout; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := negative.MaxTurns, builtinInteractionPolicy.MaxTurns; got != want {
+		t.Errorf("got %d, want %d", got, want)
+	}
+}
+
+func TestDefaultInteractionPolicy(t *testing.T) {
+	t.Cleanup(func() { SetDefaultInteractionPolicy(InteractionPolicy{}) })
+
+	if got, want := DefaultInteractionPolicy(), builtinInteractionPolicy; got != want {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+
+	SetDefaultInteractionPolicy(InteractionPolicy{MaxTurns: 50})
+	if got, want := DefaultInteractionPolicy().MaxTurns, 50; got != want {
+		t.Errorf("got %d, want %d", got, want)
+	}
+	if got, want := DefaultInteractionPolicy().TransportTimeout, builtinInteractionPolicy.TransportTimeout; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestPlayerInteractionPolicy(t *testing.T) {
+	t.Cleanup(func() { SetDefaultInteractionPolicy(InteractionPolicy{}) })
+	SetDefaultInteractionPolicy(InteractionPolicy{
+		MaxTurns:         50,
+		TransportTimeout: time.Minute,
+	})
+
+	p := &Player{}
+	p.SetInteractionPolicy(InteractionPolicy{
+		TransportTimeout:     5 * time.Second,
+		MaxTransportAttempts: 2,
+	})
+
+	policy := p.interactionPolicy()
+	if got, want := policy.TransportTimeout, 5*time.Second; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := policy.MaxTransportAttempts, 2; got != want {
+		t.Errorf("got %d, want %d", got, want)
+	}
+	if got, want := policy.MaxTurns, 50; got != want {
+		t.Errorf("got %d, want %d", got, want)
+	}
+	if got, want := policy.BackoffBase, builtinInteractionPolicy.BackoffBase; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
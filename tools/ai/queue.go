@@ -0,0 +1,71 @@
+package ai
+
+import "errors"
+
+// QueueMode controls what happens when a [Player] is asked to think while it
+// is already thinking.
+type QueueMode int
+
+const (
+	// QueueSerial queues the new interaction sequence, so all of them run
+	// one by one in order. It is the default.
+	QueueSerial QueueMode = iota
+
+	// QueueLatestWins queues the new interaction sequence and drops the ones
+	// already queued with [ErrSuperseded], so only the newest one runs after
+	// the current one.
+	QueueLatestWins
+
+	// QueueRejectWhileBusy drops the new interaction sequence with [ErrBusy].
+	QueueRejectWhileBusy
+)
+
+// String implements [fmt.Stringer].
+func (m QueueMode) String() string {
+	switch m {
+	case QueueSerial:
+		return "serial"
+	case QueueLatestWins:
+		return "latest wins"
+	case QueueRejectWhileBusy:
+		return "reject while busy"
+	}
+	return "unknown"
+}
+
+// SetQueueMode sets the [QueueMode] of the player, which controls what happens
+// when it is asked to think while it is already thinking.
+//
+// Interaction sequences dropped due to the mode end with [ErrSuperseded] or
+// [ErrBusy] in [Outcome.Err], but are not reported to the handler registered
+// via [Player.OnErr__0].
+func (p *Player) SetQueueMode(mode QueueMode) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.queueMode = mode
+	if mode == QueueLatestWins && p.interactionCond != nil {
+		// Let queued sequences check whether they are superseded.
+		p.interactionCond.Broadcast()
+	}
+}
+
+// IsThinking reports whether the player is running an interaction sequence.
+func (p *Player) IsThinking() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.interactionActive
+}
+
+// QueueLen returns the number of interaction sequences waiting for the
+// current one to finish.
+func (p *Player) QueueLen() int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.queueLen
+}
+
+// isDroppedByQueue reports whether err indicates an interaction sequence was
+// dropped due to the [QueueMode] of the player.
+func isDroppedByQueue(err error) bool {
+	return errors.Is(err, ErrSuperseded) || errors.Is(err, ErrBusy)
+}
@@ -0,0 +1,163 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// waitQueueLen waits until p has n queued interaction sequences.
+func waitQueueLen(t *testing.T, p *Player, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for p.QueueLen() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d queued sequences, want %d", p.QueueLen(), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// newBlockingPlayer returns a player whose interaction sequences block until
+// unblock is called. The contents of the requests are recorded in order.
+func newBlockingPlayer() (p *Player, contents func() []string, unblock func()) {
+	var (
+		mu   sync.Mutex
+		got  []string
+		gate = make(chan struct{})
+	)
+	p = &Player{}
+	p.SetTransport(&mockTransport{
+		InteractFunc: func(ctx context.Context, req Request) (Response, error) {
+			mu.Lock()
+			got = append(got, req.Content)
+			mu.Unlock()
+			<-gate
+			return Response{Text: "done"}, nil
+		},
+	})
+	p.OnErr__0(func(err error) {})
+	contents = func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), got...)
+	}
+	return p, contents, sync.OnceFunc(func() { close(gate) })
+}
+
+func TestPlayerQueueMode(t *testing.T) {
+	for _, tt := range []struct {
+		name         string
+		mode         QueueMode
+		wantQueueLen int
+		wantContents []string
+		wantErrs     []error
+	}{
+		{
+			name:         "Serial",
+			mode:         QueueSerial,
+			wantQueueLen: 2,
+			wantContents: []string{"first", "second", "third"},
+			wantErrs:     []error{nil, nil},
+		},
+		{
+			name:         "LatestWins",
+			mode:         QueueLatestWins,
+			wantQueueLen: 1,
+			wantContents: []string{"first", "third"},
+			wantErrs:     []error{ErrSuperseded, nil},
+		},
+		{
+			name:         "RejectWhileBusy",
+			mode:         QueueRejectWhileBusy,
+			wantQueueLen: 0,
+			wantContents: []string{"first"},
+			wantErrs:     []error{ErrBusy, ErrBusy},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			p, contents, unblock := newBlockingPlayer()
+			defer unblock()
+			p.SetQueueMode(tt.mode)
+
+			if p.IsThinking() {
+				t.Error("expected not thinking before think")
+			}
+			first := p.ThinkAsync__1("first")
+			for len(contents()) == 0 {
+				time.Sleep(time.Millisecond)
+			}
+			if !p.IsThinking() {
+				t.Error("expected thinking")
+			}
+
+			handles := []*ThinkHandle{p.ThinkAsync__1("second")}
+			if tt.mode != QueueRejectWhileBusy {
+				// Make sure sequences are queued in order.
+				waitQueueLen(t, p, 1)
+			}
+			handles = append(handles, p.ThinkAsync__1("third"))
+			// Dropped sequences finish right away.
+			for i, h := range handles {
+				if tt.wantErrs[i] != nil {
+					<-h.Done()
+				}
+			}
+			waitQueueLen(t, p, tt.wantQueueLen)
+
+			unblock()
+			if outcome := first.Wait(); outcome.Err != nil && !errors.Is(outcome.Err, ErrNoCommand) {
+				t.Errorf("unexpected error %v", outcome.Err)
+			}
+			for i, h := range handles {
+				outcome := h.Wait()
+				if tt.wantErrs[i] == nil {
+					if isDroppedByQueue(outcome.Err) {
+						t.Errorf("unexpected error %v", outcome.Err)
+					}
+				} else if !errors.Is(outcome.Err, tt.wantErrs[i]) {
+					t.Errorf("got %v, want %v", outcome.Err, tt.wantErrs[i])
+				}
+			}
+
+			got := contents()
+			if len(got) != len(tt.wantContents) {
+				t.Fatalf("got %v, want %v", got, tt.wantContents)
+			}
+			for i := range got {
+				if got[i] != tt.wantContents[i] {
+					t.Errorf("got %v, want %v", got, tt.wantContents)
+					break
+				}
+			}
+			if p.IsThinking() {
+				t.Error("expected not thinking after all sequences finished")
+			}
+		})
+	}
+}
+
+func TestPlayerQueueModeNotReported(t *testing.T) {
+	p, _, unblock := newBlockingPlayer()
+	defer unblock()
+	p.SetQueueMode(QueueRejectWhileBusy)
+	var handledErr error
+	p.OnErr__0(func(err error) { handledErr = err })
+
+	h := p.ThinkAsync__1("first")
+	for !p.IsThinking() {
+		time.Sleep(time.Millisecond)
+	}
+	outcome := p.ThinkResult__1("second")
+
+	if !errors.Is(outcome.Err, ErrBusy) {
+		t.Errorf("got %v, want %v", outcome.Err, ErrBusy)
+	}
+	if handledErr != nil {
+		t.Errorf("got %v, want nil", handledErr)
+	}
+	unblock()
+	h.Wait()
+}
@@ -0,0 +1,211 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+)
+
+// RateLimiter coordinates [Transport] calls of all players sharing it, so
+// they respect the rate limits of the backend together instead of each
+// rediscovering them.
+//
+// It waits until the latest deadline given by the Retry-After hints observed
+// from [TooManyRequestsError]s, optionally limits the call rate on the client side
+// with a token bucket, and lets waiting callers through in FIFO order.
+//
+// A zero-value RateLimiter is ready to use and has no client-side limit.
+type RateLimiter struct {
+	mu          sync.Mutex
+	nextAllowed time.Time
+	rate        float64
+	burst       int
+	tokens      float64
+	lastRefill  time.Time
+	waiters     []*rateLimitWaiter
+}
+
+// rateLimitWaiter is a caller waiting in the queue of a [RateLimiter].
+type rateLimitWaiter struct {
+	// wake is signaled when the waiter becomes the head of the queue.
+	wake chan struct{}
+}
+
+// NewRateLimiter creates a new [RateLimiter] that allows rate calls per second
+// on average, with bursts of up to burst calls. A non-positive rate means no
+// client-side limit. Burst is at least 1.
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	burst = max(burst, 1)
+	return &RateLimiter{
+		rate:   rate,
+		burst:  burst,
+		tokens: float64(burst),
+	}
+}
+
+// Wait blocks until the caller is allowed to make a [Transport] call or ctx
+// finishes. Callers are let through in the order they started waiting. It
+// returns nil when the call is allowed. Otherwise it returns ctx.Err().
+func (rl *RateLimiter) Wait(ctx context.Context) error {
+	w := &rateLimitWaiter{wake: make(chan struct{}, 1)}
+
+	rl.mu.Lock()
+	rl.waiters = append(rl.waiters, w)
+	for {
+		var delay time.Duration
+		if rl.waiters[0] == w {
+			now := time.Now()
+			delay = max(rl.nextAllowed.Sub(now), rl.tokenDelay(now))
+			if delay <= 0 {
+				rl.takeToken()
+				rl.removeWaiter(w)
+				rl.mu.Unlock()
+				return nil
+			}
+		}
+		rl.mu.Unlock()
+
+		var (
+			timer  *time.Timer
+			timerC <-chan time.Time
+		)
+		if delay > 0 {
+			timer = time.NewTimer(delay)
+			timerC = timer.C
+		}
+		var ctxErr error
+		select {
+		case <-timerC:
+		case <-w.wake:
+		case <-ctx.Done():
+			ctxErr = ctx.Err()
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+
+		rl.mu.Lock()
+		if ctxErr != nil {
+			rl.removeWaiter(w)
+			rl.mu.Unlock()
+			return ctxErr
+		}
+	}
+}
+
+// Observe records rate limiting hints from errors returned by the [Transport].
+// A shorter Retry-After never cuts short a later deadline observed before, as
+// all players sharing the RateLimiter must still respect it.
+func (rl *RateLimiter) Observe(err error) {
+	var tmrErr *TooManyRequestsError
+	if !errors.As(err, &tmrErr) {
+		return
+	}
+
+	delay := tmrErr.RetryAfter
+	if delay <= 0 {
+		delay = time.Second
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if d := time.Now().Add(delay); d.After(rl.nextAllowed) {
+		rl.nextAllowed = d
+	}
+	if len(rl.waiters) > 0 {
+		rl.waiters[0].signal()
+	}
+}
+
+// tokenDelay refills the token bucket and returns how long to wait for the
+// next token. The caller must hold rl.mu.
+func (rl *RateLimiter) tokenDelay(now time.Time) time.Duration {
+	if rl.rate <= 0 {
+		return 0
+	}
+	if !rl.lastRefill.IsZero() {
+		elapsed := now.Sub(rl.lastRefill).Seconds()
+		rl.tokens = min(rl.tokens+elapsed*rl.rate, float64(rl.burst))
+	}
+	rl.lastRefill = now
+	if rl.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - rl.tokens) / rl.rate * float64(time.Second))
+}
+
+// takeToken consumes a token from the token bucket. The caller must hold
+// rl.mu.
+func (rl *RateLimiter) takeToken() {
+	if rl.rate > 0 {
+		rl.tokens--
+	}
+}
+
+// removeWaiter removes w from the queue and wakes up the new head if w was
+// the head. The caller must hold rl.mu.
+func (rl *RateLimiter) removeWaiter(w *rateLimitWaiter) {
+	i := slices.Index(rl.waiters, w)
+	if i < 0 {
+		return
+	}
+	rl.waiters = slices.Delete(rl.waiters, i, i+1)
+	if i == 0 && len(rl.waiters) > 0 {
+		rl.waiters[0].signal()
+	}
+}
+
+// signal wakes up the waiter without blocking.
+func (w *rateLimitWaiter) signal() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+var (
+	// defaultRateLimiter holds the default instance of [RateLimiter].
+	defaultRateLimiter   = &RateLimiter{}
+	defaultRateLimiterMu sync.RWMutex
+)
+
+// DefaultRateLimiter returns the default [RateLimiter] instance, which is
+// shared by all players that don't set their own via
+// [Player.SetRateLimiter].
+func DefaultRateLimiter() *RateLimiter {
+	defaultRateLimiterMu.RLock()
+	defer defaultRateLimiterMu.RUnlock()
+	return defaultRateLimiter
+}
+
+// SetDefaultRateLimiter sets the default instance of [RateLimiter]. It resets
+// to a new [RateLimiter] with no client-side limit if nil is provided.
+func SetDefaultRateLimiter(rl *RateLimiter) {
+	defaultRateLimiterMu.Lock()
+	defer defaultRateLimiterMu.Unlock()
+	if rl == nil {
+		rl = &RateLimiter{}
+	}
+	defaultRateLimiter = rl
+}
+
+// rateLimiter returns the [RateLimiter] used for the player's [Transport]
+// calls. It falls back to [DefaultRateLimiter] if no custom one is set via
+// [Player.SetRateLimiter]. The caller must hold p.mu.
+func (p *Player) rateLimiter() *RateLimiter {
+	if p.customRateLimiter != nil {
+		return p.customRateLimiter
+	}
+	return DefaultRateLimiter()
+}
+
+// SetRateLimiter sets a custom [RateLimiter] for the player, e.g., to share
+// one among the players using the same [Transport]. It resets to
+// [DefaultRateLimiter] if nil is provided.
+func (p *Player) SetRateLimiter(rl *RateLimiter) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.customRateLimiter = rl
+}
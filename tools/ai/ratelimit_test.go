@@ -0,0 +1,185 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	t.Run("ObserveSetsNextAllowed", func(t *testing.T) {
+		var rl RateLimiter
+		start := time.Now()
+		rl.Observe(&TooManyRequestsError{RetryAfter: 25 * time.Millisecond})
+
+		expectedMin := start.Add(25 * time.Millisecond)
+		if rl.nextAllowed.Before(expectedMin) {
+			t.Errorf("nextAllowed %v before expected minimum %v", rl.nextAllowed, expectedMin)
+		}
+	})
+
+	t.Run("ObserveKeepsLaterDeadline", func(t *testing.T) {
+		initial := time.Now().Add(200 * time.Millisecond)
+		rl := RateLimiter{nextAllowed: initial}
+
+		rl.Observe(&TooManyRequestsError{RetryAfter: 10 * time.Millisecond})
+		if rl.nextAllowed != initial {
+			t.Errorf("nextAllowed %v changed from %v", rl.nextAllowed, initial)
+		}
+	})
+
+	t.Run("ObserveIgnoresNonRateErrors", func(t *testing.T) {
+		var rl RateLimiter
+		rl.Observe(errors.New("other"))
+		if !rl.nextAllowed.IsZero() {
+			t.Errorf("nextAllowed %v, want zero time", rl.nextAllowed)
+		}
+	})
+
+	t.Run("WaitBlocksUntilAllowed", func(t *testing.T) {
+		delay := 30 * time.Millisecond
+		rl := RateLimiter{nextAllowed: time.Now().Add(delay)}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		t.Cleanup(cancel)
+		start := time.Now()
+
+		if err := rl.Wait(ctx); err != nil {
+			t.Fatalf("wait returned error: %v", err)
+		}
+		if elapsed := time.Since(start); elapsed < delay {
+			t.Fatalf("wait returned too early: elapsed %v, want at least %v", elapsed, delay)
+		}
+	})
+
+	t.Run("WaitReturnsImmediatelyWhenAllowed", func(t *testing.T) {
+		var rl RateLimiter
+		if err := rl.Wait(context.Background()); err != nil {
+			t.Fatalf("wait returned error: %v", err)
+		}
+	})
+
+	t.Run("WaitRespectsContextTimeout", func(t *testing.T) {
+		rl := RateLimiter{nextAllowed: time.Now().Add(time.Second)}
+		ctx, cancel := context.WithTimeout(context.Background(), 25*time.Millisecond)
+		t.Cleanup(cancel)
+
+		if err := rl.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected deadline exceeded, got %v", err)
+		}
+		if got := len(rl.waiters); got != 0 {
+			t.Errorf("got %d waiters, want 0", got)
+		}
+	})
+
+	t.Run("TokenBucket", func(t *testing.T) {
+		rl := NewRateLimiter(20, 2)
+		start := time.Now()
+		for range 3 {
+			if err := rl.Wait(context.Background()); err != nil {
+				t.Fatalf("wait returned error: %v", err)
+			}
+		}
+
+		// The first 2 calls use the burst, the third waits for a new token.
+		if elapsed, want := time.Since(start), 40*time.Millisecond; elapsed < want {
+			t.Errorf("wait returned too early: elapsed %v, want at least %v", elapsed, want)
+		}
+	})
+
+	t.Run("FIFO", func(t *testing.T) {
+		rl := RateLimiter{nextAllowed: time.Now().Add(30 * time.Millisecond)}
+
+		var (
+			mu    sync.Mutex
+			order []int
+			wg    sync.WaitGroup
+		)
+		for i := range 5 {
+			wg.Go(func() {
+				if err := rl.Wait(context.Background()); err != nil {
+					t.Errorf("wait returned error: %v", err)
+					return
+				}
+				mu.Lock()
+				order = append(order, i)
+				mu.Unlock()
+			})
+
+			// Make sure waiters are queued in order.
+			for {
+				rl.mu.Lock()
+				n := len(rl.waiters)
+				rl.mu.Unlock()
+				if n == i+1 {
+					break
+				}
+				time.Sleep(time.Millisecond)
+			}
+		}
+		wg.Wait()
+
+		for i, got := range order {
+			if got != i {
+				t.Fatalf("got order %v, want ascending", order)
+			}
+		}
+	})
+}
+
+func TestPlayerRateLimiter(t *testing.T) {
+	p := &Player{}
+	if got, want := p.rateLimiter(), DefaultRateLimiter(); got != want {
+		t.Errorf("got %p, want %p", got, want)
+	}
+
+	rl := NewRateLimiter(1, 1)
+	p.SetRateLimiter(rl)
+	if got, want := p.rateLimiter(), rl; got != want {
+		t.Errorf("got %p, want %p", got, want)
+	}
+
+	p.SetRateLimiter(nil)
+	if got, want := p.rateLimiter(), DefaultRateLimiter(); got != want {
+		t.Errorf("got %p, want %p", got, want)
+	}
+}
+
+func TestPlayerThinkSharesRateLimiter(t *testing.T) {
+	var (
+		mu         sync.Mutex
+		interacted []time.Time
+	)
+	transport := &mockTransport{
+		InteractFunc: func(ctx context.Context, req Request) (Response, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			interacted = append(interacted, time.Now())
+			if len(interacted) == 1 {
+				return Response{}, &TooManyRequestsError{RetryAfter: 50 * time.Millisecond}
+			}
+			return Response{Text: "done"}, nil
+		},
+	}
+	rl := &RateLimiter{}
+	policy := InteractionPolicy{MaxTransportAttempts: 1}
+	p1, p2 := &Player{}, &Player{}
+	for _, p := range []*Player{p1, p2} {
+		p.SetTransport(transport)
+		p.SetRateLimiter(rl)
+		p.SetInteractionPolicy(policy)
+		p.OnErr__0(func(err error) {})
+	}
+
+	p1.Think__1("hello")
+	p2.Think__1("hello")
+
+	// The second player must respect the Retry-After observed by the first.
+	if got, want := len(interacted), 2; got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+	if elapsed, want := interacted[1].Sub(interacted[0]), 50*time.Millisecond; elapsed < want {
+		t.Errorf("got %v, want at least %v", elapsed, want)
+	}
+}
@@ -0,0 +1,47 @@
+package replaytrans
+
+import "strings"
+
+// diffLines returns a line-based diff that turns a into b, with removed
+// lines prefixed by "- ", added lines by "+ " and unchanged lines by "  ",
+// along with the number of changed lines.
+func diffLines(a, b string) (diff string, changes int) {
+	al := strings.Split(a, "\n")
+	bl := strings.Split(b, "\n")
+
+	// lcs[i][j] is the length of the longest common subsequence of al[i:]
+	// and bl[j:].
+	lcs := make([][]int, len(al)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(bl)+1)
+	}
+	for i := len(al) - 1; i >= 0; i-- {
+		for j := len(bl) - 1; j >= 0; j-- {
+			if al[i] == bl[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var sb strings.Builder
+	i, j := 0, 0
+	for i < len(al) || j < len(bl) {
+		switch {
+		case i < len(al) && j < len(bl) && al[i] == bl[j]:
+			sb.WriteString("  " + al[i] + "\n")
+			i++
+			j++
+		case i < len(al) && (j == len(bl) || lcs[i+1][j] >= lcs[i][j+1]):
+			sb.WriteString("- " + al[i] + "\n")
+			changes++
+			i++
+		default:
+			sb.WriteString("+ " + bl[j] + "\n")
+			changes++
+			j++
+		}
+	}
+	return sb.String(), changes
+}
@@ -0,0 +1,402 @@
+// Package replaytrans provides a Transport implementation that records AI
+// interactions to a cassette file and replays them later, so games can be
+// tested deterministically without the live AI backend.
+package replaytrans
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/goplus/builder/tools/ai"
+)
+
+// CassetteVersion is the version of the cassette file format.
+const CassetteVersion = 1
+
+// ErrNoMatch indicates that no recorded interaction matches a request in
+// replay mode.
+var ErrNoMatch = errors.New("no recorded interaction matches the request")
+
+// cassette is the content of a cassette file.
+type cassette struct {
+	Version      int           `json:"version"`
+	Interactions []interaction `json:"interactions"`
+	Archives     []archive     `json:"archives,omitempty"`
+}
+
+// interaction is a recorded [ai.Transport.Interact] call.
+type interaction struct {
+	Request  matchedRequest `json:"request"`
+	Response ai.Response    `json:"response"`
+	Error    *recordedError `json:"error,omitempty"`
+}
+
+// archive is a recorded [ai.Transport.Archive] call.
+type archive struct {
+	Request  matchedArchive     `json:"request"`
+	Response ai.ArchivedHistory `json:"response"`
+	Error    *recordedError     `json:"error,omitempty"`
+}
+
+// matchedRequest is the normalized part of an [ai.Request] used to match
+// recorded interactions. Other parts, like the history, depend on earlier
+// interactions, so they are left out to keep cassettes robust.
+type matchedRequest struct {
+	Content          string           `json:"content,omitempty"`
+	Context          map[string]any   `json:"context,omitempty"`
+	CommandSpecs     []ai.CommandSpec `json:"commandSpecs,omitempty"`
+	ContinuationTurn int              `json:"continuationTurn,omitempty"`
+}
+
+// matchedArchive is the part of an [ai.Transport.Archive] call used to match
+// recorded archives.
+type matchedArchive struct {
+	Turns           []ai.Turn `json:"turns"`
+	ExistingArchive string    `json:"existingArchive,omitempty"`
+}
+
+// recordedError is a recorded error returned by the wrapped [ai.Transport].
+// Rate limiting errors are recorded with their types, so they are replayed
+// as the same types.
+type recordedError struct {
+	Message    string        `json:"message"`
+	Kind       string        `json:"kind,omitempty"`
+	RetryAfter time.Duration `json:"retryAfter,omitempty"`
+}
+
+const (
+	errorKindTooManyRequests = "tooManyRequests"
+	errorKindQuotaExceeded   = "quotaExceeded"
+)
+
+// newRecordedError converts err to a [recordedError]. It returns nil if err
+// is nil.
+func newRecordedError(err error) *recordedError {
+	if err == nil {
+		return nil
+	}
+	re := &recordedError{Message: err.Error()}
+	var (
+		tmrErr *ai.TooManyRequestsError
+		qeErr  *ai.QuotaExceededError
+	)
+	switch {
+	case errors.As(err, &tmrErr):
+		re.Kind = errorKindTooManyRequests
+		re.RetryAfter = tmrErr.RetryAfter
+	case errors.As(err, &qeErr):
+		re.Kind = errorKindQuotaExceeded
+		re.RetryAfter = qeErr.RetryAfter
+	}
+	return re
+}
+
+// err converts the recorded error back to an error.
+func (re *recordedError) err() error {
+	if re == nil {
+		return nil
+	}
+	err := errors.New(re.Message)
+	switch re.Kind {
+	case errorKindTooManyRequests:
+		return &ai.TooManyRequestsError{RetryAfter: re.RetryAfter, Err: err}
+	case errorKindQuotaExceeded:
+		return &ai.QuotaExceededError{RetryAfter: re.RetryAfter, Err: err}
+	}
+	return err
+}
+
+// normalizeRequest returns the normalized part of req used for matching.
+// Command specs are sorted by name, and the context is converted to its JSON
+// form, so equivalent requests match regardless of map order or number
+// types.
+func normalizeRequest(req ai.Request) (matchedRequest, error) {
+	specs := slices.Clone(req.CommandSpecs)
+	slices.SortFunc(specs, func(a, b ai.CommandSpec) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+	mr := matchedRequest{
+		Content:          req.Content,
+		Context:          req.Context,
+		CommandSpecs:     specs,
+		ContinuationTurn: req.ContinuationTurn,
+	}
+	return roundTripJSON(mr)
+}
+
+// normalizeArchive returns the normalized form of an archive call used for
+// matching.
+func normalizeArchive(turns []ai.Turn, existingArchive string) (matchedArchive, error) {
+	return roundTripJSON(matchedArchive{
+		Turns:           turns,
+		ExistingArchive: existingArchive,
+	})
+}
+
+// roundTripJSON returns v as it would be read back from a cassette file.
+func roundTripJSON[T any](v T) (T, error) {
+	var rt T
+	b, err := json.Marshal(v)
+	if err != nil {
+		return rt, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	if err := json.Unmarshal(b, &rt); err != nil {
+		return rt, fmt.Errorf("failed to unmarshal request: %w", err)
+	}
+	return rt, nil
+}
+
+// recorder implements [ai.Transport] by recording the calls to a wrapped
+// [ai.Transport].
+type recorder struct {
+	path      string
+	transport ai.Transport
+
+	mu       sync.Mutex
+	cassette cassette
+}
+
+// Record creates a new [ai.Transport] that forwards calls to transport and
+// records them to the cassette file at path. The file is created or
+// truncated right away, and rewritten after every call.
+//
+// If transport implements [ai.StreamingTransport], so does the returned
+// [ai.Transport], so streamed text still reaches the player while recording.
+// Only the complete responses are recorded, and they are replayed without
+// streaming.
+func Record(path string, transport ai.Transport) (ai.Transport, error) {
+	r := &recorder{
+		path:      path,
+		transport: transport,
+		cassette:  cassette{Version: CassetteVersion},
+	}
+	if err := r.save(); err != nil {
+		return nil, err
+	}
+	if st, ok := transport.(ai.StreamingTransport); ok {
+		return &streamingRecorder{recorder: r, transport: st}, nil
+	}
+	return r, nil
+}
+
+// Interact implements [ai.Transport].
+func (r *recorder) Interact(ctx context.Context, req ai.Request) (ai.Response, error) {
+	return r.interact(ctx, req, func() (ai.Response, error) {
+		return r.transport.Interact(ctx, req)
+	})
+}
+
+// interact records the interaction made by calling call for req.
+func (r *recorder) interact(ctx context.Context, req ai.Request, call func() (ai.Response, error)) (ai.Response, error) {
+	mr, err := normalizeRequest(req)
+	if err != nil {
+		return ai.Response{}, err
+	}
+	resp, err := call()
+	if ctx.Err() != nil {
+		// Don't record calls canceled by the caller, as they won't be made
+		// the same way in replays.
+		return resp, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cassette.Interactions = append(r.cassette.Interactions, interaction{
+		Request:  mr,
+		Response: resp,
+		Error:    newRecordedError(err),
+	})
+	if saveErr := r.save(); saveErr != nil {
+		return ai.Response{}, saveErr
+	}
+	return resp, err
+}
+
+// streamingRecorder is a [recorder] of an [ai.StreamingTransport].
+type streamingRecorder struct {
+	*recorder
+	transport ai.StreamingTransport
+}
+
+// InteractStream implements [ai.StreamingTransport].
+func (r *streamingRecorder) InteractStream(ctx context.Context, req ai.Request, onText func(delta string)) (ai.Response, error) {
+	return r.interact(ctx, req, func() (ai.Response, error) {
+		return r.transport.InteractStream(ctx, req, onText)
+	})
+}
+
+// Archive implements [ai.Transport].
+func (r *recorder) Archive(ctx context.Context, turns []ai.Turn, existingArchive string) (ai.ArchivedHistory, error) {
+	ma, err := normalizeArchive(turns, existingArchive)
+	if err != nil {
+		return ai.ArchivedHistory{}, err
+	}
+	archived, err := r.transport.Archive(ctx, turns, existingArchive)
+	if ctx.Err() != nil {
+		return archived, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cassette.Archives = append(r.cassette.Archives, archive{
+		Request:  ma,
+		Response: archived,
+		Error:    newRecordedError(err),
+	})
+	if saveErr := r.save(); saveErr != nil {
+		return ai.ArchivedHistory{}, saveErr
+	}
+	return archived, err
+}
+
+// save writes the cassette to the file. It writes to a temporary file first
+// and renames it, so an interrupted save never leaves a partial cassette
+// behind. The caller must hold r.mu.
+func (r *recorder) save() error {
+	data, err := json.MarshalIndent(r.cassette, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal cassette: %w", err)
+	}
+	f, err := os.CreateTemp(filepath.Dir(r.path), ".cassette-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	tmpPath := f.Name()
+	defer os.Remove(tmpPath)
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close temporary file: %w", err)
+	}
+	if err := os.Rename(tmpPath, r.path); err != nil {
+		return fmt.Errorf("failed to rename temporary file: %w", err)
+	}
+	return nil
+}
+
+// replayer implements [ai.Transport] by replaying recorded calls.
+type replayer struct {
+	mu               sync.Mutex
+	interactions     []interaction
+	usedInteractions []bool
+	archives         []archive
+	usedArchives     []bool
+}
+
+// Replay creates a new [ai.Transport] that replays the calls recorded in the
+// cassette file at path via [Record].
+//
+// Each call is answered by the first unused recorded call with a matching
+// request, so identical requests are answered in the recorded order. A
+// request matches if its content, context, command specs and continuation
+// turn are the same as recorded. If no recorded call matches, the call fails
+// with [ErrNoMatch] and a diff against the closest recorded request.
+func Replay(path string) (ai.Transport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cassette file: %w", err)
+	}
+	var c cassette
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal cassette: %w", err)
+	}
+	if c.Version != CassetteVersion {
+		return nil, fmt.Errorf("unsupported cassette version %d", c.Version)
+	}
+	return &replayer{
+		interactions:     c.Interactions,
+		usedInteractions: make([]bool, len(c.Interactions)),
+		archives:         c.Archives,
+		usedArchives:     make([]bool, len(c.Archives)),
+	}, nil
+}
+
+// Interact implements [ai.Transport].
+func (r *replayer) Interact(ctx context.Context, req ai.Request) (ai.Response, error) {
+	if err := ctx.Err(); err != nil {
+		return ai.Response{}, err
+	}
+	mr, err := normalizeRequest(req)
+	if err != nil {
+		return ai.Response{}, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i, err := match(mr, r.interactions, r.usedInteractions, func(i interaction) matchedRequest {
+		return i.Request
+	})
+	if err != nil {
+		return ai.Response{}, err
+	}
+	r.usedInteractions[i] = true
+	return r.interactions[i].Response, r.interactions[i].Error.err()
+}
+
+// Archive implements [ai.Transport].
+func (r *replayer) Archive(ctx context.Context, turns []ai.Turn, existingArchive string) (ai.ArchivedHistory, error) {
+	if err := ctx.Err(); err != nil {
+		return ai.ArchivedHistory{}, err
+	}
+	ma, err := normalizeArchive(turns, existingArchive)
+	if err != nil {
+		return ai.ArchivedHistory{}, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i, err := match(ma, r.archives, r.usedArchives, func(a archive) matchedArchive {
+		return a.Request
+	})
+	if err != nil {
+		return ai.ArchivedHistory{}, err
+	}
+	r.usedArchives[i] = true
+	return r.archives[i].Response, r.archives[i].Error.err()
+}
+
+// match returns the index of the first unused recorded call whose request
+// matches req. If there is none, it returns an error wrapping [ErrNoMatch]
+// with a diff against the closest request of the unused recorded calls.
+func match[R, C any](req R, calls []C, used []bool, requestOf func(C) R) (int, error) {
+	got, err := json.MarshalIndent(req, "", "  ")
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	var (
+		closest        = -1
+		closestDiff    string
+		closestChanges int
+	)
+	for i, call := range calls {
+		if used[i] {
+			continue
+		}
+		want, err := json.MarshalIndent(requestOf(call), "", "  ")
+		if err != nil {
+			return 0, fmt.Errorf("failed to marshal recorded request: %w", err)
+		}
+		if string(want) == string(got) {
+			return i, nil
+		}
+		diff, changes := diffLines(string(want), string(got))
+		if closest < 0 || changes < closestChanges {
+			closest, closestDiff, closestChanges = i, diff, changes
+		}
+	}
+	if closest < 0 {
+		return 0, fmt.Errorf("%w: all %d recorded calls are used, got request:\n%s", ErrNoMatch, len(calls), got)
+	}
+	return 0, fmt.Errorf("%w: diff against recorded call %d (-recorded +got):\n%s", ErrNoMatch, closest, closestDiff)
+}
@@ -0,0 +1,256 @@
+package replaytrans
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goplus/builder/tools/ai"
+)
+
+// fakeTransport is an [ai.Transport] backed by funcs.
+type fakeTransport struct {
+	InteractFunc func(ctx context.Context, req ai.Request) (ai.Response, error)
+	ArchiveFunc  func(ctx context.Context, turns []ai.Turn, existingArchive string) (ai.ArchivedHistory, error)
+}
+
+// Interact implements [ai.Transport].
+func (f *fakeTransport) Interact(ctx context.Context, req ai.Request) (ai.Response, error) {
+	return f.InteractFunc(ctx, req)
+}
+
+// Archive implements [ai.Transport].
+func (f *fakeTransport) Archive(ctx context.Context, turns []ai.Turn, existingArchive string) (ai.ArchivedHistory, error) {
+	return f.ArchiveFunc(ctx, turns, existingArchive)
+}
+
+// fakeStreamingTransport is an [ai.StreamingTransport] backed by funcs.
+type fakeStreamingTransport struct {
+	fakeTransport
+	InteractStreamFunc func(ctx context.Context, req ai.Request, onText func(delta string)) (ai.Response, error)
+}
+
+// InteractStream implements [ai.StreamingTransport].
+func (f *fakeStreamingTransport) InteractStream(ctx context.Context, req ai.Request, onText func(delta string)) (ai.Response, error) {
+	return f.InteractStreamFunc(ctx, req, onText)
+}
+
+// mustRecord calls [Record] and fails the test on error.
+func mustRecord(t *testing.T, path string, transport ai.Transport) ai.Transport {
+	t.Helper()
+	record, err := Record(path, transport)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	return record
+}
+
+type MoveCmd struct {
+	Steps int
+}
+
+type JumpCmd struct{}
+
+// newPlayer returns a player that uses transport and records the steps of
+// executed MoveCmd commands.
+func newPlayer(transport ai.Transport, steps *[]int) *ai.Player {
+	p := &ai.Player{}
+	p.SetTransport(transport)
+	p.OnErr__0(func(err error) {})
+	ai.XGot_Player_XGox_OnCmd(p, func(cmd MoveCmd) error {
+		*steps = append(*steps, cmd.Steps)
+		return nil
+	})
+	ai.XGot_Player_XGox_OnCmd(p, func(cmd JumpCmd) error { return nil })
+	return p
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+
+	var liveCalls int
+	live := &fakeTransport{
+		InteractFunc: func(ctx context.Context, req ai.Request) (ai.Response, error) {
+			liveCalls++
+			if req.ContinuationTurn == 0 {
+				return ai.Response{Text: "moving", CommandName: "MoveCmd", CommandArgs: map[string]any{"Steps": 3}}, nil
+			}
+			return ai.Response{Text: "done"}, nil
+		},
+	}
+	var recordedSteps []int
+	recorded := newPlayer(mustRecord(t, path, live), &recordedSteps).ThinkResult__0("go", map[string]any{"x": 1})
+	if recorded.Err != nil {
+		t.Fatalf("unexpected error %v", recorded.Err)
+	}
+
+	replay, err := Replay(path)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	var replayedSteps []int
+	replayed := newPlayer(replay, &replayedSteps).ThinkResult__0("go", map[string]any{"x": 1.0})
+	if replayed.Err != nil {
+		t.Fatalf("unexpected error %v", replayed.Err)
+	}
+
+	if got, want := liveCalls, 2; got != want {
+		t.Errorf("got %d, want %d", got, want)
+	}
+	if got, want := replayedSteps, recordedSteps; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := replayed.Text, "done"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestReplayMismatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	live := &fakeTransport{
+		InteractFunc: func(ctx context.Context, req ai.Request) (ai.Response, error) {
+			return ai.Response{Text: "hi"}, nil
+		},
+	}
+	if _, err := mustRecord(t, path, live).Interact(context.Background(), ai.Request{Content: "hello"}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	replay, err := Replay(path)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	_, err = replay.Interact(context.Background(), ai.Request{Content: "bye"})
+	if !errors.Is(err, ErrNoMatch) {
+		t.Fatalf("got %v, want %v", err, ErrNoMatch)
+	}
+	for _, wantSubstr := range []string{`-   "content": "hello"`, `+   "content": "bye"`} {
+		if got := err.Error(); !strings.Contains(got, wantSubstr) {
+			t.Errorf("got %q, want substring %q", got, wantSubstr)
+		}
+	}
+
+	if _, err := replay.Interact(context.Background(), ai.Request{Content: "hello"}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	_, err = replay.Interact(context.Background(), ai.Request{Content: "hello"})
+	if got, wantSubstr := err.Error(), "all 1 recorded calls are used"; !strings.Contains(got, wantSubstr) {
+		t.Errorf("got %q, want substring %q", got, wantSubstr)
+	}
+}
+
+func TestReplayErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	live := &fakeTransport{
+		InteractFunc: func(ctx context.Context, req ai.Request) (ai.Response, error) {
+			return ai.Response{}, &ai.TooManyRequestsError{RetryAfter: 5 * time.Second, Err: errors.New("slow down")}
+		},
+		ArchiveFunc: func(ctx context.Context, turns []ai.Turn, existingArchive string) (ai.ArchivedHistory, error) {
+			return ai.ArchivedHistory{}, errors.New("archive failed")
+		},
+	}
+	record := mustRecord(t, path, live)
+	record.Interact(context.Background(), ai.Request{Content: "hello"})
+	record.Archive(context.Background(), []ai.Turn{{RequestContent: "hello"}}, "")
+
+	replay, err := Replay(path)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	_, err = replay.Interact(context.Background(), ai.Request{Content: "hello"})
+	var tmrErr *ai.TooManyRequestsError
+	if !errors.As(err, &tmrErr) {
+		t.Fatalf("got %v, want %T", err, tmrErr)
+	}
+	if got, want := tmrErr.RetryAfter, 5*time.Second; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	_, err = replay.Archive(context.Background(), []ai.Turn{{RequestContent: "hello"}}, "")
+	if got, want := err.Error(), "archive failed"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestRecord(t *testing.T) {
+	t.Run("EmptyCassette", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "cassette.json")
+		mustRecord(t, path, &fakeTransport{})
+
+		replay, err := Replay(path)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if _, err := replay.Interact(context.Background(), ai.Request{Content: "hello"}); !errors.Is(err, ErrNoMatch) {
+			t.Errorf("got %v, want %v", err, ErrNoMatch)
+		}
+	})
+
+	t.Run("InvalidPath", func(t *testing.T) {
+		if _, err := Record(filepath.Join(t.TempDir(), "missing", "cassette.json"), &fakeTransport{}); err == nil {
+			t.Error("expected error")
+		}
+	})
+
+	t.Run("Streaming", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "cassette.json")
+		live := &fakeStreamingTransport{
+			InteractStreamFunc: func(ctx context.Context, req ai.Request, onText func(delta string)) (ai.Response, error) {
+				onText("h")
+				onText("i")
+				return ai.Response{Text: "hi"}, nil
+			},
+		}
+		record, ok := mustRecord(t, path, live).(ai.StreamingTransport)
+		if !ok {
+			t.Fatal("expected ai.StreamingTransport")
+		}
+		var deltas []string
+		if _, err := record.InteractStream(context.Background(), ai.Request{Content: "hello"}, func(delta string) {
+			deltas = append(deltas, delta)
+		}); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if got, want := deltas, []string{"h", "i"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+
+		replay, err := Replay(path)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		resp, err := replay.Interact(context.Background(), ai.Request{Content: "hello"})
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if got, want := resp.Text, "hi"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("NotStreaming", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "cassette.json")
+		if _, ok := mustRecord(t, path, &fakeTransport{}).(ai.StreamingTransport); ok {
+			t.Error("expected no ai.StreamingTransport")
+		}
+	})
+}
+
+func TestReplayInvalidCassette(t *testing.T) {
+	if _, err := Replay(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	diff, changes := diffLines("a\nb\nc", "a\nx\nc")
+	if got, want := diff, "  a\n- b\n+ x\n  c\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := changes, 2; got != want {
+		t.Errorf("got %d, want %d", got, want)
+	}
+}
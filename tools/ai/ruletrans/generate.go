@@ -0,0 +1,146 @@
+package ruletrans
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"strings"
+)
+
+const (
+	// defaultMinimum and defaultMaximum bound generated numbers, within the
+	// range allowed by the schema, so they stay plausible for games.
+	defaultMinimum = 0
+	defaultMaximum = 10
+
+	// defaultMaxItems is the maximum number of generated array items, if not
+	// fixed by the schema.
+	defaultMaxItems = 3
+)
+
+// generateValue returns a plausible random value matching the JSON Schema
+// fragment schema, which describes a value named name.
+func generateValue(r *rand.Rand, schema map[string]any, name string) any {
+	if v, ok := schema["default"]; ok {
+		return v
+	}
+	if enum, ok := schema["enum"].([]any); ok && len(enum) > 0 {
+		return enum[r.IntN(len(enum))]
+	}
+
+	switch schemaType(schema) {
+	case "boolean":
+		return r.IntN(2) == 1
+	case "integer":
+		lo, hi := numberRange(schema)
+		lo, hi = math.Ceil(lo), math.Floor(hi)
+		if hi < lo {
+			return int(lo)
+		}
+		return int(lo) + r.IntN(int(hi-lo)+1)
+	case "number":
+		lo, hi := numberRange(schema)
+		return math.Round((lo+r.Float64()*(hi-lo))*100) / 100
+	case "string":
+		return strings.ToLower(name)
+	case "array":
+		items, _ := schema["items"].(map[string]any)
+		minItems, hasMin := toFloat(schema["minItems"])
+		maxItems, hasMax := toFloat(schema["maxItems"])
+		if !hasMin {
+			minItems = 1
+		}
+		if !hasMax {
+			maxItems = max(minItems, defaultMaxItems)
+		}
+		n := int(minItems)
+		if maxItems > minItems {
+			n += r.IntN(int(maxItems-minItems) + 1)
+		}
+		values := make([]any, n)
+		for i := range values {
+			values[i] = generateValue(r, items, name)
+		}
+		return values
+	case "object":
+		properties, _ := schema["properties"].(map[string]any)
+		values := make(map[string]any, len(properties))
+		// Iterate in a stable order, so the same source of randomness
+		// generates the same values.
+		for _, key := range slices.Sorted(maps.Keys(properties)) {
+			property, _ := properties[key].(map[string]any)
+			values[key] = generateValue(r, property, key)
+		}
+		return values
+	}
+
+	// Schemas without a type accept any value.
+	return nil
+}
+
+// schemaType returns the type of schema, ignoring "null" in type lists of
+// nullable values.
+func schemaType(schema map[string]any) string {
+	switch t := schema["type"].(type) {
+	case string:
+		return t
+	case []any:
+		for _, v := range t {
+			if s, ok := v.(string); ok && s != "null" {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// numberRange returns the range of generated numbers for schema: the default
+// range clamped to the minimum and maximum of schema. If they don't overlap,
+// the default range is moved next to the allowed range.
+func numberRange(schema map[string]any) (lo, hi float64) {
+	lo, hi = defaultMinimum, defaultMaximum
+	minimum, hasMin := toFloat(schema["minimum"])
+	maximum, hasMax := toFloat(schema["maximum"])
+	switch {
+	case hasMin && minimum > hi:
+		lo, hi = minimum, minimum+defaultMaximum-defaultMinimum
+	case hasMax && maximum < lo:
+		lo, hi = maximum-defaultMaximum+defaultMinimum, maximum
+	}
+	if hasMin {
+		lo = max(lo, minimum)
+	}
+	if hasMax {
+		hi = min(hi, maximum)
+	}
+	return lo, hi
+}
+
+// toFloat converts a JSON Schema number of any numeric type to float64.
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// marshalString returns the JSON encoding of v as a string. Values that
+// cannot be encoded are formatted with fmt instead.
+func marshalString(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
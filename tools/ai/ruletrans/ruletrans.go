@@ -0,0 +1,220 @@
+// Package ruletrans provides an offline Transport implementation that answers
+// AI interactions with simple rules registered by the game author, so games
+// still work, e.g., for classroom demos, when no AI is available.
+package ruletrans
+
+import (
+	"context"
+	"maps"
+	"math/rand/v2"
+	"strings"
+	"sync"
+
+	"github.com/goplus/builder/tools/ai"
+)
+
+// Predicate reports whether a rule applies to a request.
+type Predicate func(req ai.Request) bool
+
+// Keyword returns a [Predicate] that matches requests whose content contains
+// any of keywords, ignoring case.
+func Keyword(keywords ...string) Predicate {
+	return func(req ai.Request) bool {
+		content := strings.ToLower(req.Content)
+		for _, keyword := range keywords {
+			if strings.Contains(content, strings.ToLower(keyword)) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// ContextEquals returns a [Predicate] that matches requests whose context has
+// value under key. Values are compared in their JSON form, so, e.g., the int
+// 1 equals the float64 1.
+func ContextEquals(key string, value any) Predicate {
+	want := marshalString(value)
+	return func(req ai.Request) bool {
+		v, ok := req.Context[key]
+		return ok && marshalString(v) == want
+	}
+}
+
+// ContextFunc returns a [Predicate] that matches requests whose context
+// satisfies fn. fn receives nil if the request has no context.
+func ContextFunc(fn func(context map[string]any) bool) Predicate {
+	return func(req ai.Request) bool {
+		return fn(req.Context)
+	}
+}
+
+// All returns a [Predicate] that matches requests matching all of preds.
+func All(preds ...Predicate) Predicate {
+	return func(req ai.Request) bool {
+		for _, pred := range preds {
+			if !pred(req) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Rule maps requests to a command.
+type Rule struct {
+	// When reports whether the rule applies to a request. A nil When matches
+	// every request.
+	When Predicate
+
+	// Command is the name of the command to ask for. The rule is skipped if
+	// the command is not in [ai.Request.CommandSpecs].
+	Command string
+
+	// Args holds the arguments for the command. Parameters missing from Args
+	// are generated from their specs.
+	Args map[string]any
+
+	// Text is the text of the response.
+	Text string
+}
+
+// ruleTransport implements [ai.Transport] by applying rules.
+type ruleTransport struct {
+	rules          []Rule
+	randomFallback bool
+	fallbackText   string
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// Option is a function type for configuring the [ruleTransport].
+type Option func(*ruleTransport)
+
+// WithRule adds a rule. Rules are tried in the order they are added.
+func WithRule(rule Rule) Option {
+	return func(t *ruleTransport) {
+		t.rules = append(t.rules, rule)
+	}
+}
+
+// WithKeywordRule adds a rule asking for command with args when the request
+// content contains any of keywords. See [Keyword].
+func WithKeywordRule(keywords []string, command string, args map[string]any) Option {
+	return WithRule(Rule{When: Keyword(keywords...), Command: command, Args: args})
+}
+
+// WithRandomFallback makes requests that match no rule ask for a random
+// command among [ai.Request.CommandSpecs], with arguments generated from its
+// parameter specs.
+func WithRandomFallback() Option {
+	return func(t *ruleTransport) {
+		t.randomFallback = true
+	}
+}
+
+// WithFallbackText sets the text of the response to requests that match no
+// rule while the random fallback is disabled. The response asks for no
+// command.
+func WithFallbackText(text string) Option {
+	return func(t *ruleTransport) {
+		t.fallbackText = text
+	}
+}
+
+// WithRand sets the source of randomness used to pick random commands and
+// generate arguments, e.g., to make tests deterministic.
+func WithRand(r *rand.Rand) Option {
+	return func(t *ruleTransport) {
+		t.rand = r
+	}
+}
+
+// New creates a new [ai.Transport] that answers requests with rules, without
+// any AI.
+//
+// Only the initial turn of an interaction sequence is answered with a
+// command: the first rule that matches the request and whose command is
+// among [ai.Request.CommandSpecs] wins. If no rule matches, a random command
+// is asked for if [WithRandomFallback] is set. Otherwise the response only
+// has the fallback text (see [WithFallbackText]), so the interaction sequence
+// fails with [ai.ErrNoCommand], which games can handle via
+// [ai.Player.OnErr__0]. Continuation turns are answered without a command,
+// which ends the sequence.
+//
+// History is archived via [ai.SummarizeLocally].
+func New(opts ...Option) ai.Transport {
+	t := &ruleTransport{
+		fallbackText: "I'm not sure what to do.",
+		rand:         rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64())),
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// Interact implements [ai.Transport].
+func (t *ruleTransport) Interact(ctx context.Context, req ai.Request) (ai.Response, error) {
+	if err := ctx.Err(); err != nil {
+		return ai.Response{}, err
+	}
+	if req.ContinuationTurn > 0 {
+		return ai.Response{}, nil
+	}
+
+	specs := make(map[string]ai.CommandSpec, len(req.CommandSpecs))
+	for _, spec := range req.CommandSpecs {
+		specs[spec.Name] = spec
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, rule := range t.rules {
+		spec, ok := specs[rule.Command]
+		if !ok || (rule.When != nil && !rule.When(req)) {
+			continue
+		}
+		return ai.Response{
+			Text:        rule.Text,
+			CommandName: spec.Name,
+			CommandArgs: t.completeArgs(spec, rule.Args),
+		}, nil
+	}
+	if t.randomFallback && len(req.CommandSpecs) > 0 {
+		spec := req.CommandSpecs[t.rand.IntN(len(req.CommandSpecs))]
+		return ai.Response{
+			CommandName: spec.Name,
+			CommandArgs: t.completeArgs(spec, nil),
+		}, nil
+	}
+	return ai.Response{Text: t.fallbackText}, nil
+}
+
+// completeArgs returns a copy of args with the parameters of spec missing
+// from args generated from their specs. The caller must hold t.mu.
+func (t *ruleTransport) completeArgs(spec ai.CommandSpec, args map[string]any) map[string]any {
+	if len(spec.Parameters) == 0 {
+		return maps.Clone(args)
+	}
+	completed := make(map[string]any, len(spec.Parameters))
+	for _, param := range spec.Parameters {
+		if _, ok := args[param.Name]; ok {
+			continue
+		}
+		completed[param.Name] = generateValue(t.rand, param.Schema, param.Name)
+	}
+	for name, value := range args {
+		completed[name] = value
+	}
+	return completed
+}
+
+// Archive implements [ai.Transport].
+func (t *ruleTransport) Archive(ctx context.Context, turns []ai.Turn, existingArchive string) (ai.ArchivedHistory, error) {
+	if err := ctx.Err(); err != nil {
+		return ai.ArchivedHistory{}, err
+	}
+	return ai.ArchivedHistory{Content: ai.SummarizeLocally(turns, existingArchive)}, nil
+}
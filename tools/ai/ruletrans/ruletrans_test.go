@@ -0,0 +1,222 @@
+package ruletrans
+
+import (
+	"context"
+	"math/rand/v2"
+	"reflect"
+	"testing"
+
+	"github.com/goplus/builder/tools/ai"
+	"github.com/goplus/builder/tools/ai/aitest"
+)
+
+var testSpecs = []ai.CommandSpec{
+	{Name: "Attack", Parameters: []ai.CommandParamSpec{
+		{Name: "Target", Type: "string", Required: true, Schema: map[string]any{"type": "string"}},
+		{Name: "Power", Type: "int", Required: true, Schema: map[string]any{"type": "integer", "minimum": 1.0, "maximum": 3.0}},
+	}},
+	{Name: "Heal"},
+}
+
+func TestInteract(t *testing.T) {
+	transport := New(
+		WithRule(Rule{When: Keyword("fly"), Command: "Fly", Text: "Whee!"}),
+		WithKeywordRule([]string{"ATTACK", "fight"}, "Attack", map[string]any{"Target": "dragon"}),
+		WithRule(Rule{When: ContextFunc(func(ctx map[string]any) bool {
+			hp, ok := ctx["HP"].(int)
+			return ok && hp < 3
+		}), Command: "Heal", Text: "Ouch!"}),
+		WithRule(Rule{When: All(Keyword("rest"), ContextEquals("Night", true)), Command: "Heal"}),
+		WithRand(rand.New(rand.NewPCG(1, 2))),
+	)
+
+	for _, tt := range []struct {
+		name     string
+		req      ai.Request
+		wantName string
+		wantText string
+		wantArgs map[string]any
+	}{
+		{
+			name:     "Keyword",
+			req:      ai.Request{Content: "Let's fight!", CommandSpecs: testSpecs},
+			wantName: "Attack",
+		},
+		{
+			name:     "SkipsUnknownCommand",
+			req:      ai.Request{Content: "fly and attack", CommandSpecs: testSpecs},
+			wantName: "Attack",
+		},
+		{
+			name:     "Context",
+			req:      ai.Request{Content: "Hello", Context: map[string]any{"HP": 1}, CommandSpecs: testSpecs},
+			wantName: "Heal",
+			wantText: "Ouch!",
+		},
+		{
+			name:     "ContextEquals",
+			req:      ai.Request{Content: "Time to rest", Context: map[string]any{"Night": true}, CommandSpecs: testSpecs},
+			wantName: "Heal",
+		},
+		{
+			name:     "NoMatch",
+			req:      ai.Request{Content: "Time to rest", Context: map[string]any{"Night": false}, CommandSpecs: testSpecs},
+			wantText: "I'm not sure what to do.",
+		},
+		{
+			name:     "NotInSpecs",
+			req:      ai.Request{Content: "attack", CommandSpecs: testSpecs[1:]},
+			wantText: "I'm not sure what to do.",
+		},
+		{
+			name: "ContinuationTurn",
+			req:  ai.Request{Content: "attack", CommandSpecs: testSpecs, ContinuationTurn: 1},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := transport.Interact(context.Background(), tt.req)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got, want := resp.CommandName, tt.wantName; got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+			if got, want := resp.Text, tt.wantText; got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+			if resp.CommandName == "Attack" {
+				if got, want := resp.CommandArgs["Target"], "dragon"; got != want {
+					t.Errorf("got %v, want %v", got, want)
+				}
+				power, _ := resp.CommandArgs["Power"].(int)
+				if power < 1 || power > 3 {
+					t.Errorf("got power %v, want between 1 and 3", resp.CommandArgs["Power"])
+				}
+			}
+		})
+	}
+}
+
+func TestInteractCopiesArgs(t *testing.T) {
+	args := map[string]any{"Amount": 1}
+	transport := New(WithKeywordRule([]string{"heal"}, "Heal", args))
+
+	resp, err := transport.Interact(context.Background(), ai.Request{Content: "heal", CommandSpecs: testSpecs})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.CommandArgs["Amount"] = 2
+	if got, want := args["Amount"], 1; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+type Move struct {
+	Direction string   `enum:"up,down,left,right"`
+	Steps     int      `min:"1" max:"3"`
+	Speed     float64  `min:"0.5" max:"2"`
+	Cells     []int    `min:"0" max:"8"`
+	Fast      bool     `default:"true"`
+	Target    *float64 `min:"100"`
+}
+
+type Jump struct {
+	Height int `min:"-5" max:"-1"`
+}
+
+func TestRandomFallback(t *testing.T) {
+	transport := New(WithRandomFallback(), WithRand(rand.New(rand.NewPCG(1, 2))))
+	p := aitest.NewPlayer(transport)
+	var (
+		moves []Move
+		jumps []Jump
+	)
+	ai.XGot_Player_XGox_OnCmd(p, func(cmd Move) error {
+		moves = append(moves, cmd)
+		return nil
+	})
+	ai.XGot_Player_XGox_OnCmd(p, func(cmd Jump) error {
+		jumps = append(jumps, cmd)
+		return nil
+	})
+	cmds := aitest.RecordCommands(p)
+
+	for range 20 {
+		if outcome := p.ThinkResult__1("do something"); outcome.Err != nil {
+			t.Fatalf("unexpected error: %v", outcome.Err)
+		}
+	}
+
+	if got, want := len(cmds.Commands()), 20; got != want {
+		t.Fatalf("got %d commands, want %d", got, want)
+	}
+	for _, cmd := range cmds.Commands() {
+		if !cmd.Result.Success {
+			t.Errorf("command %s %v failed: %s", cmd.Name, cmd.Args, cmd.Result.ErrorMessage)
+		}
+	}
+	if len(moves) == 0 || len(jumps) == 0 {
+		t.Fatalf("got %d moves and %d jumps, want both picked", len(moves), len(jumps))
+	}
+	for _, move := range moves {
+		if move.Steps < 1 || move.Steps > 3 {
+			t.Errorf("got steps %d, want between 1 and 3", move.Steps)
+		}
+		if move.Speed < 0.5 || move.Speed > 2 {
+			t.Errorf("got speed %v, want between 0.5 and 2", move.Speed)
+		}
+		if got, want := move.Fast, true; got != want {
+			t.Errorf("got %t, want %t", got, want)
+		}
+		if move.Target != nil && (*move.Target < 100 || *move.Target > 110) {
+			t.Errorf("got target %v, want between 100 and 110", *move.Target)
+		}
+		if len(move.Cells) < 1 || len(move.Cells) > 3 {
+			t.Errorf("got %d cells, want between 1 and 3", len(move.Cells))
+		}
+	}
+	for _, jump := range jumps {
+		if jump.Height < -5 || jump.Height > -1 {
+			t.Errorf("got height %d, want between -5 and -1", jump.Height)
+		}
+	}
+}
+
+func TestArchive(t *testing.T) {
+	turns := []ai.Turn{{RequestContent: "Hello", ResponseText: "Hi!", IsInitial: true}}
+
+	archived, err := New().Archive(context.Background(), turns, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got, want := archived.Content, ai.SummarizeLocally(turns, ""); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestGenerateValue(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	for _, tt := range []struct {
+		name   string
+		schema map[string]any
+		check  func(v any) bool
+	}{
+		{"Default", map[string]any{"type": "integer", "default": 7}, func(v any) bool { return v == 7 }},
+		{"Enum", map[string]any{"type": "string", "enum": []any{"a"}}, func(v any) bool { return v == "a" }},
+		{"Nullable", map[string]any{"type": []any{"boolean", "null"}}, func(v any) bool { _, ok := v.(bool); return ok }},
+		{"FixedArray", map[string]any{"type": "array", "items": map[string]any{"type": "integer"}, "minItems": 2, "maxItems": 2}, func(v any) bool {
+			return reflect.ValueOf(v).Len() == 2
+		}},
+		{"Object", map[string]any{"type": "object", "properties": map[string]any{"X": map[string]any{"type": "integer", "minimum": 5, "maximum": 5}}}, func(v any) bool {
+			return reflect.DeepEqual(v, map[string]any{"X": 5})
+		}},
+		{"Any", map[string]any{}, func(v any) bool { return v == nil }},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if v := generateValue(r, tt.schema, "Name"); !tt.check(v) {
+				t.Errorf("unexpected value %#v", v)
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package ai
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+)
+
+// Scheduler limits how many AI interaction turns of all players run at the
+// same time. When the limit is reached, turns wait in a queue ordered by the
+// priority of their players (see [Player.SetPriority]), so important players
+// like a boss get their turns before ambient chatter. When the queue is too
+// deep, the lowest priority initial turn is dropped with [ErrDropped].
+// Continuation turns are never dropped, as their sequences already executed
+// commands.
+//
+// A turn holds its slot only while calling the [Transport]. It does not while
+// waiting for the [RateLimiter], backing off between retries or executing
+// commands, and acquires the slot again for each retry.
+//
+// A zero-value Scheduler is ready to use and has no limit.
+type Scheduler struct {
+	mu            sync.Mutex
+	maxConcurrent int
+	maxQueued     int
+	running       int
+	queue         []*schedulerTicket
+	nextSeq       uint64
+}
+
+// schedulerTicket is a turn waiting in the queue of a [Scheduler].
+type schedulerTicket struct {
+	priority  int
+	seq       uint64
+	droppable bool
+
+	// ready receives nil when the turn is allowed to run, or [ErrDropped]
+	// when it is dropped from the queue.
+	ready chan error
+}
+
+// NewScheduler creates a new [Scheduler] that runs at most maxConcurrent
+// turns at the same time, and keeps at most maxQueued turns waiting. A
+// non-positive maxConcurrent means no limit, and a non-positive maxQueued
+// means no limit on the queue.
+func NewScheduler(maxConcurrent, maxQueued int) *Scheduler {
+	return &Scheduler{
+		maxConcurrent: maxConcurrent,
+		maxQueued:     maxQueued,
+	}
+}
+
+// acquire waits until a turn with priority is allowed to run. The returned
+// release func must be called once the turn finishes. If droppable, it
+// returns [ErrDropped] if the turn is dropped from the queue. Turns that are
+// not droppable are always queued and don't count towards maxQueued. It
+// returns ctx.Err() if ctx finishes first.
+func (s *Scheduler) acquire(ctx context.Context, priority int, droppable bool) (release func(), err error) {
+	s.mu.Lock()
+	if s.maxConcurrent <= 0 || (s.running < s.maxConcurrent && len(s.queue) == 0) {
+		s.running++
+		s.mu.Unlock()
+		return sync.OnceFunc(s.release), nil
+	}
+
+	ticket := &schedulerTicket{
+		priority:  priority,
+		seq:       s.nextSeq,
+		droppable: droppable,
+		ready:     make(chan error, 1),
+	}
+	s.nextSeq++
+	if droppable && s.maxQueued > 0 && s.countDroppable() >= s.maxQueued {
+		// The queue is ordered by priority, so the last droppable ticket is
+		// the lowest priority and most recent one that can be dropped.
+		victim := len(s.queue) - 1
+		for !s.queue[victim].droppable {
+			victim--
+		}
+		if s.queue[victim].priority >= priority {
+			s.mu.Unlock()
+			return nil, ErrDropped
+		}
+		s.queue[victim].ready <- ErrDropped
+		s.queue = slices.Delete(s.queue, victim, victim+1)
+	}
+	i, _ := slices.BinarySearchFunc(s.queue, ticket, compareSchedulerTickets)
+	s.queue = slices.Insert(s.queue, i, ticket)
+	s.mu.Unlock()
+
+	select {
+	case err := <-ticket.ready:
+		if err != nil {
+			return nil, err
+		}
+		return sync.OnceFunc(s.release), nil
+	case <-ctx.Done():
+	}
+
+	s.mu.Lock()
+	if i := slices.Index(s.queue, ticket); i >= 0 {
+		s.queue = slices.Delete(s.queue, i, i+1)
+		s.mu.Unlock()
+		return nil, ctx.Err()
+	}
+	s.mu.Unlock()
+
+	// The ticket was dispatched or dropped right before ctx finished.
+	if err := <-ticket.ready; err == nil {
+		s.release()
+	}
+	return nil, ctx.Err()
+}
+
+// countDroppable returns the number of queued tickets that can be dropped.
+// Only these count towards maxQueued. The caller must hold s.mu.
+func (s *Scheduler) countDroppable() int {
+	n := 0
+	for _, ticket := range s.queue {
+		if ticket.droppable {
+			n++
+		}
+	}
+	return n
+}
+
+// release frees the slot of a finished turn and lets the next queued turn
+// run, if any.
+func (s *Scheduler) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.running--
+	for len(s.queue) > 0 && s.running < s.maxConcurrent {
+		ticket := s.queue[0]
+		s.queue = s.queue[1:]
+		s.running++
+		ticket.ready <- nil
+	}
+}
+
+// compareSchedulerTickets orders tickets by descending priority, then by
+// arrival.
+func compareSchedulerTickets(a, b *schedulerTicket) int {
+	if c := cmp.Compare(b.priority, a.priority); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.seq, b.seq)
+}
+
+var (
+	// defaultScheduler holds the default instance of [Scheduler].
+	defaultScheduler   = &Scheduler{}
+	defaultSchedulerMu sync.RWMutex
+)
+
+// DefaultScheduler returns the [Scheduler] shared by all players. By default,
+// it has no limit, so turns never wait or get dropped: most games have only
+// a few players, which a limit would only delay, and the [RateLimiter]
+// already keeps them within the backend limits. Games that run many players
+// opt in to a limit with [SetDefaultScheduler].
+func DefaultScheduler() *Scheduler {
+	defaultSchedulerMu.RLock()
+	defer defaultSchedulerMu.RUnlock()
+	return defaultScheduler
+}
+
+// SetDefaultScheduler sets the [Scheduler] shared by all players. It resets
+// to a new [Scheduler] with no limit if nil is provided.
+//
+// Turns already waiting in the previous [Scheduler] keep waiting there.
+func SetDefaultScheduler(s *Scheduler) {
+	defaultSchedulerMu.Lock()
+	defer defaultSchedulerMu.Unlock()
+	if s == nil {
+		s = &Scheduler{}
+	}
+	defaultScheduler = s
+}
+
+// scheduler returns the [Scheduler] that runs the player's turns. It falls
+// back to [DefaultScheduler] if no custom one is set via
+// [Player.SetScheduler]. The caller must hold p.mu.
+func (p *Player) scheduler() *Scheduler {
+	if p.customScheduler != nil {
+		return p.customScheduler
+	}
+	return DefaultScheduler()
+}
+
+// SetScheduler sets a custom [Scheduler] for the player, e.g., to keep it out
+// of the limits shared by other players. It resets to [DefaultScheduler] if
+// nil is provided.
+func (p *Player) SetScheduler(s *Scheduler) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.customScheduler = s
+}
+
+// SetPriority sets the priority of the player's turns in its [Scheduler].
+// Turns of players with higher priority run first when too many players are
+// thinking at the same time, and are kept when the queue is too deep. The
+// default priority is 0.
+//
+// It is safe to call SetPriority while the player is thinking. The change
+// takes effect from the next turn.
+func (p *Player) SetPriority(priority int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.priority = priority
+}
@@ -0,0 +1,273 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// waitQueued waits until s has n queued turns.
+func waitQueued(t *testing.T, s *Scheduler, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		s.mu.Lock()
+		got := len(s.queue)
+		s.mu.Unlock()
+		if got == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d queued turns, want %d", got, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestScheduler(t *testing.T) {
+	t.Run("Unlimited", func(t *testing.T) {
+		var s Scheduler
+		for range 10 {
+			if _, err := s.acquire(context.Background(), 0, true); err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+		}
+	})
+
+	t.Run("PriorityOrder", func(t *testing.T) {
+		s := NewScheduler(1, 0)
+		release, err := s.acquire(context.Background(), 0, true)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+
+		order := make(chan int, 3)
+		for i, priority := range []int{0, 10, 5} {
+			go func() {
+				release, err := s.acquire(context.Background(), priority, true)
+				if err != nil {
+					t.Errorf("unexpected error %v", err)
+					return
+				}
+				order <- priority
+				release()
+			}()
+			waitQueued(t, s, i+1)
+		}
+		release()
+
+		for _, want := range []int{10, 5, 0} {
+			if got := <-order; got != want {
+				t.Errorf("got %d, want %d", got, want)
+			}
+		}
+	})
+
+	t.Run("DropLowestWhenQueueFull", func(t *testing.T) {
+		s := NewScheduler(1, 1)
+		release, err := s.acquire(context.Background(), 0, true)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+
+		lowErr := make(chan error, 1)
+		go func() {
+			_, err := s.acquire(context.Background(), 0, true)
+			lowErr <- err
+		}()
+		waitQueued(t, s, 1)
+
+		// Same priority is dropped instead of the queued one.
+		if _, err := s.acquire(context.Background(), 0, true); !errors.Is(err, ErrDropped) {
+			t.Errorf("got %v, want %v", err, ErrDropped)
+		}
+
+		// Higher priority replaces the queued one.
+		highErr := make(chan error, 1)
+		go func() {
+			release, err := s.acquire(context.Background(), 1, true)
+			if err == nil {
+				release()
+			}
+			highErr <- err
+		}()
+		if err := <-lowErr; !errors.Is(err, ErrDropped) {
+			t.Errorf("got %v, want %v", err, ErrDropped)
+		}
+		release()
+		if err := <-highErr; err != nil {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+
+	t.Run("NotDroppable", func(t *testing.T) {
+		s := NewScheduler(1, 1)
+		release, err := s.acquire(context.Background(), 0, true)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+
+		order := make(chan int, 2)
+		for i, priority := range []int{1, 0} {
+			go func() {
+				release, err := s.acquire(context.Background(), priority, priority == 1)
+				if err != nil {
+					t.Errorf("unexpected error %v", err)
+					return
+				}
+				order <- priority
+				release()
+			}()
+			waitQueued(t, s, i+1)
+		}
+
+		// The full queue has a droppable turn of higher priority, and the
+		// turn that is not droppable is never dropped.
+		if _, err := s.acquire(context.Background(), 0, true); !errors.Is(err, ErrDropped) {
+			t.Errorf("got %v, want %v", err, ErrDropped)
+		}
+		release()
+
+		for _, want := range []int{1, 0} {
+			if got := <-order; got != want {
+				t.Errorf("got %d, want %d", got, want)
+			}
+		}
+	})
+
+	t.Run("CancelWhileQueued", func(t *testing.T) {
+		s := NewScheduler(1, 0)
+		release, err := s.acquire(context.Background(), 0, true)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		if _, err := s.acquire(ctx, 0, true); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("got %v, want %v", err, context.DeadlineExceeded)
+		}
+		waitQueued(t, s, 0)
+
+		release()
+		release() // Releasing twice is a no-op.
+		if got, want := s.running, 0; got != want {
+			t.Errorf("got %d, want %d", got, want)
+		}
+	})
+}
+
+func TestPlayerThinkDropped(t *testing.T) {
+	s := NewScheduler(1, 1)
+	SetDefaultScheduler(s)
+	t.Cleanup(func() { SetDefaultScheduler(nil) })
+
+	release, err := s.acquire(context.Background(), 0, true)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer release()
+	go s.acquire(context.Background(), 0, true)
+	waitQueued(t, s, 1)
+
+	p := &Player{}
+	p.SetTransport(&mockTransport{
+		InteractFunc: func(ctx context.Context, req Request) (Response, error) {
+			t.Error("unexpected transport call")
+			return Response{}, nil
+		},
+	})
+	var handledErr error
+	p.OnErr__0(func(err error) { handledErr = err })
+
+	outcome := p.ThinkResult__1("hello")
+
+	if !errors.Is(outcome.Err, ErrDropped) {
+		t.Errorf("got %v, want %v", outcome.Err, ErrDropped)
+	}
+	if got, want := handledErr, outcome.Err; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestPlayerThinkNotDroppedMidSequence(t *testing.T) {
+	s := NewScheduler(1, 1)
+	SetDefaultScheduler(s)
+	t.Cleanup(func() { SetDefaultScheduler(nil) })
+
+	p := &Player{}
+	p.SetTransport(&mockTransport{
+		InteractFunc: func(ctx context.Context, req Request) (Response, error) {
+			if req.ContinuationTurn == 0 {
+				return Response{CommandName: "NoopCmd"}, nil
+			}
+			return Response{Text: "done"}, nil
+		},
+	})
+	XGot_Player_XGox_OnCmd(p, func(cmd NoopCmd) error {
+		// Fill the scheduler while the command runs, so the continuation
+		// turn has to wait behind a full queue.
+		release, err := s.acquire(context.Background(), 0, true)
+		if err != nil {
+			t.Errorf("unexpected error %v", err)
+			return nil
+		}
+		go func() {
+			if release, err := s.acquire(context.Background(), 10, true); err == nil {
+				release()
+			}
+		}()
+		waitQueued(t, s, 1)
+		go func() {
+			waitQueued(t, s, 2)
+			release()
+		}()
+		return nil
+	})
+
+	outcome := p.ThinkResult__1("hello")
+
+	if outcome.Err != nil {
+		t.Errorf("unexpected error: %v", outcome.Err)
+	}
+	if got, want := outcome.Text, "done"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestPlayerSetScheduler(t *testing.T) {
+	s := NewScheduler(1, 1)
+	SetDefaultScheduler(s)
+	t.Cleanup(func() { SetDefaultScheduler(nil) })
+
+	// Fill the default scheduler.
+	release, err := s.acquire(context.Background(), 0, true)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer release()
+	go s.acquire(context.Background(), 0, true)
+	waitQueued(t, s, 1)
+
+	p := &Player{}
+	p.SetTransport(&mockTransport{
+		InteractFunc: func(ctx context.Context, req Request) (Response, error) {
+			if req.ContinuationTurn == 0 {
+				return Response{CommandName: "NoopCmd"}, nil
+			}
+			return Response{Text: "done"}, nil
+		},
+	})
+	XGot_Player_XGox_OnCmd(p, func(cmd NoopCmd) error { return nil })
+	p.SetScheduler(&Scheduler{})
+
+	if outcome := p.ThinkResult__1("hello"); outcome.Err != nil {
+		t.Errorf("unexpected error: %v", outcome.Err)
+	}
+
+	p.SetScheduler(nil)
+	if outcome := p.ThinkResult__1("hello"); !errors.Is(outcome.Err, ErrDropped) {
+		t.Errorf("got %v, want %v", outcome.Err, ErrDropped)
+	}
+}
@@ -0,0 +1,107 @@
+package ai
+
+import (
+	"math"
+	"reflect"
+)
+
+// jsonSchemaBuilder builds JSON Schema fragments for Go types.
+type jsonSchemaBuilder struct {
+	// tags holds the parsed struct tags of the struct types.
+	tags structTags
+
+	// visiting holds the struct types currently being built, so recursive
+	// types don't cause infinite recursion.
+	visiting []reflect.Type
+}
+
+// build returns a JSON Schema fragment for typ.
+func (b *jsonSchemaBuilder) build(typ reflect.Type) map[string]any {
+	switch typ.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Int8:
+		return map[string]any{"type": "integer", "minimum": math.MinInt8, "maximum": math.MaxInt8}
+	case reflect.Int16:
+		return map[string]any{"type": "integer", "minimum": math.MinInt16, "maximum": math.MaxInt16}
+	case reflect.Int32:
+		return map[string]any{"type": "integer", "minimum": math.MinInt32, "maximum": math.MaxInt32}
+	case reflect.Uint, reflect.Uint64, reflect.Uintptr:
+		return map[string]any{"type": "integer", "minimum": 0}
+	case reflect.Uint8:
+		return map[string]any{"type": "integer", "minimum": 0, "maximum": math.MaxUint8}
+	case reflect.Uint16:
+		return map[string]any{"type": "integer", "minimum": 0, "maximum": math.MaxUint16}
+	case reflect.Uint32:
+		return map[string]any{"type": "integer", "minimum": 0, "maximum": int64(math.MaxUint32)}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": b.build(typ.Elem())}
+	case reflect.Array:
+		return map[string]any{
+			"type":     "array",
+			"items":    b.build(typ.Elem()),
+			"minItems": typ.Len(),
+			"maxItems": typ.Len(),
+		}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": b.build(typ.Elem())}
+	case reflect.Pointer:
+		schema := b.build(typ.Elem())
+		if t, ok := schema["type"].(string); ok {
+			schema["type"] = []any{t, "null"}
+		}
+		return schema
+	case reflect.Struct:
+		return b.buildStruct(typ)
+	}
+
+	// Interfaces and other kinds accept any value.
+	return map[string]any{}
+}
+
+// buildStruct returns a JSON Schema fragment for a struct type, describing
+// its exported fields as properties.
+func (b *jsonSchemaBuilder) buildStruct(typ reflect.Type) map[string]any {
+	for _, visiting := range b.visiting {
+		if visiting == typ {
+			return map[string]any{"type": "object"}
+		}
+	}
+	b.visiting = append(b.visiting, typ)
+	defer func() { b.visiting = b.visiting[:len(b.visiting)-1] }()
+
+	properties := map[string]any{}
+	required := []any{}
+	fieldTags := b.tags.of(typ)
+	for i := range typ.NumField() {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tags := fieldTags[i]
+		properties[tags.name] = b.buildField(field, tags)
+		if tags.isRequired() {
+			required = append(required, tags.name)
+		}
+	}
+	return map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}
+
+// buildField returns a JSON Schema fragment for a struct field, including the
+// description and constraints declared in its parsed struct tags.
+func (b *jsonSchemaBuilder) buildField(field reflect.StructField, tags paramTags) map[string]any {
+	schema := b.build(field.Type)
+	tags.applyToSchema(schema, field.Type)
+	return schema
+}
@@ -0,0 +1,159 @@
+package ai
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+type schemaPoint struct {
+	X int `desc:"Column index"`
+	Y int
+}
+
+type schemaTree struct {
+	Value    string
+	Children []schemaTree
+	Parent   *schemaTree
+}
+
+func TestJSONSchemaBuilder(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		typ  reflect.Type
+		want map[string]any
+	}{
+		{
+			name: "Bool",
+			typ:  reflect.TypeFor[bool](),
+			want: map[string]any{"type": "boolean"},
+		},
+		{
+			name: "Int",
+			typ:  reflect.TypeFor[int](),
+			want: map[string]any{"type": "integer"},
+		},
+		{
+			name: "Int8",
+			typ:  reflect.TypeFor[int8](),
+			want: map[string]any{"type": "integer", "minimum": math.MinInt8, "maximum": math.MaxInt8},
+		},
+		{
+			name: "Uint",
+			typ:  reflect.TypeFor[uint](),
+			want: map[string]any{"type": "integer", "minimum": 0},
+		},
+		{
+			name: "Uint32",
+			typ:  reflect.TypeFor[uint32](),
+			want: map[string]any{"type": "integer", "minimum": 0, "maximum": int64(math.MaxUint32)},
+		},
+		{
+			name: "Float",
+			typ:  reflect.TypeFor[float32](),
+			want: map[string]any{"type": "number"},
+		},
+		{
+			name: "String",
+			typ:  reflect.TypeFor[string](),
+			want: map[string]any{"type": "string"},
+		},
+		{
+			name: "Slice",
+			typ:  reflect.TypeFor[[]string](),
+			want: map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+		},
+		{
+			name: "Array",
+			typ:  reflect.TypeFor[[3]bool](),
+			want: map[string]any{
+				"type":     "array",
+				"items":    map[string]any{"type": "boolean"},
+				"minItems": 3,
+				"maxItems": 3,
+			},
+		},
+		{
+			name: "Map",
+			typ:  reflect.TypeFor[map[string]float64](),
+			want: map[string]any{"type": "object", "additionalProperties": map[string]any{"type": "number"}},
+		},
+		{
+			name: "Pointer",
+			typ:  reflect.TypeFor[*int](),
+			want: map[string]any{"type": []any{"integer", "null"}},
+		},
+		{
+			name: "Interface",
+			typ:  reflect.TypeFor[any](),
+			want: map[string]any{},
+		},
+		{
+			name: "Struct",
+			typ:  reflect.TypeFor[schemaPoint](),
+			want: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"X": map[string]any{"type": "integer", "description": "Column index"},
+					"Y": map[string]any{"type": "integer"},
+				},
+				"required":             []any{},
+				"additionalProperties": false,
+			},
+		},
+		{
+			name: "RecursiveStruct",
+			typ:  reflect.TypeFor[schemaTree](),
+			want: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"Value":    map[string]any{"type": "string"},
+					"Children": map[string]any{"type": "array", "items": map[string]any{"type": "object"}},
+					"Parent":   map[string]any{"type": []any{"object", "null"}},
+				},
+				"required":             []any{},
+				"additionalProperties": false,
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			st := structTags{}
+			if err := st.parse(tt.typ); err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if got, want := (&jsonSchemaBuilder{tags: st}).build(tt.typ), tt.want; !reflect.DeepEqual(got, want) {
+				t.Errorf("got %#v, want %#v", got, want)
+			}
+		})
+	}
+}
+
+func TestCommandSpecParametersSchema(t *testing.T) {
+	type PlaceCmd struct {
+		At   schemaPoint `desc:"Where to place"`
+		Note *string
+	}
+
+	got := extractCommandSpec(reflect.TypeFor[PlaceCmd](), nil).ParametersSchema()
+	want := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"At": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"X": map[string]any{"type": "integer", "description": "Column index"},
+					"Y": map[string]any{"type": "integer"},
+				},
+				"required":             []any{},
+				"additionalProperties": false,
+				"description":          "Where to place",
+			},
+			"Note": map[string]any{"type": []any{"string", "null"}},
+		},
+		"required":             []any{},
+		"additionalProperties": false,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+}
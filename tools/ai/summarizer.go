@@ -0,0 +1,138 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// localSummaryMaxSize is the maximum size in bytes of the archived history
+	// produced by [SummarizeLocally].
+	localSummaryMaxSize = 4 * 1024
+
+	// localSummaryMaxFieldSize is the maximum size in bytes of each request
+	// content, response text or command arguments in the local summary.
+	localSummaryMaxFieldSize = 120
+)
+
+// SummarizeLocally condenses turns into the archived history without the
+// help of the AI. It is used to archive history when [Transport.Archive]
+// fails, and by transports without an AI behind them. The summary is
+// deterministic: it lists the request contents, responses and executed
+// commands with their outcomes, one line each, appended to existingArchive.
+//
+// The summary is kept within 4 KiB. existingArchive, which is usually the
+// better summary made by the AI, is kept as a whole if possible: the oldest
+// new lines are dropped first. Only if existingArchive takes more than half
+// of the size when there are new lines, it is cut at the end.
+func SummarizeLocally(turns []Turn, existingArchive string) string {
+	var lines []string
+	for _, turn := range turns {
+		if turn.IsInitial {
+			lines = append(lines, "Asked: "+truncateSummaryField(turn.RequestContent))
+		}
+		if turn.IsInterrupted {
+			lines = append(lines, "  Interrupted before responding")
+			continue
+		}
+		if turn.ResponseText != "" {
+			lines = append(lines, "  Replied: "+truncateSummaryField(turn.ResponseText))
+		}
+		calls, results := turnCommandCalls(turn)
+		for i, call := range calls {
+			var result *CommandResult
+			if i < len(results) {
+				result = results[i]
+			}
+			lines = append(lines, fmt.Sprintf("  Ran %s(%s): %s", call.Name, summarizeArgs(call.Args), summarizeResult(result)))
+		}
+	}
+
+	// Cut existingArchive only as far as needed to leave room for the new
+	// lines, but never below half of localSummaryMaxSize.
+	newSize := 0
+	for _, line := range lines {
+		newSize += len(line) + 1
+	}
+	archiveMaxSize := max(localSummaryMaxSize-newSize, localSummaryMaxSize/2)
+	if len(existingArchive) > archiveMaxSize {
+		existingArchive = truncateAtRuneBoundary(existingArchive, archiveMaxSize-len("...")) + "..."
+	}
+
+	// Drop the oldest new lines to fit in localSummaryMaxSize.
+	size := len(existingArchive)
+	start := len(lines)
+	for start > 0 && size+len(lines[start-1])+1 <= localSummaryMaxSize {
+		start--
+		size += len(lines[start]) + 1
+	}
+	lines = lines[start:]
+	if existingArchive != "" {
+		lines = append([]string{existingArchive}, lines...)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// turnCommandCalls returns the command calls requested in turn and their
+// results.
+func turnCommandCalls(turn Turn) ([]CommandCall, []*CommandResult) {
+	if len(turn.ResponseCommandCalls) > 0 {
+		return turn.ResponseCommandCalls, turn.ExecutedCommandResults
+	}
+	if turn.ResponseCommandName != "" {
+		return []CommandCall{{Name: turn.ResponseCommandName, Args: turn.ResponseCommandArgs}}, []*CommandResult{turn.ExecutedCommandResult}
+	}
+	return nil, nil
+}
+
+// summarizeArgs returns the JSON encoding of args for the local summary.
+// Map keys are sorted by [json.Marshal], so the result is deterministic.
+func summarizeArgs(args map[string]any) string {
+	if len(args) == 0 {
+		return ""
+	}
+	b, err := json.Marshal(args)
+	if err != nil {
+		return "..."
+	}
+	return truncateSummaryField(string(b))
+}
+
+// summarizeResult describes the outcome of a command for the local summary.
+func summarizeResult(result *CommandResult) string {
+	switch {
+	case result == nil:
+		return "not executed"
+	case result.IsBreak:
+		return "ended the interaction"
+	case result.Success:
+		return "succeeded"
+	default:
+		return "failed: " + truncateSummaryField(result.ErrorMessage)
+	}
+}
+
+// truncateSummaryField collapses s to a single line and truncates it to
+// [localSummaryMaxFieldSize] bytes without splitting UTF-8 characters.
+func truncateSummaryField(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if len(s) <= localSummaryMaxFieldSize {
+		return s
+	}
+	return truncateAtRuneBoundary(s, localSummaryMaxFieldSize) + "..."
+}
+
+// truncateAtRuneBoundary truncates s to at most n bytes without splitting
+// UTF-8 characters.
+func truncateAtRuneBoundary(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	end := n
+	for end > 0 && !utf8.RuneStart(s[end]) {
+		end--
+	}
+	return s[:end]
+}
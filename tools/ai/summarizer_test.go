@@ -0,0 +1,120 @@
+package ai
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSummarizeLocally(t *testing.T) {
+	for _, tt := range []struct {
+		name            string
+		turns           []Turn
+		existingArchive string
+		want            string
+	}{
+		{
+			name: "SingleCommand",
+			turns: []Turn{
+				{
+					RequestContent:        "Player moved",
+					IsInitial:             true,
+					ResponseText:          "My turn.",
+					ResponseCommandName:   "MakeMove",
+					ResponseCommandArgs:   map[string]any{"Row": 1.0, "Col": 2.0},
+					ExecutedCommandResult: &CommandResult{Success: true, IsBreak: true},
+				},
+			},
+			want: "Asked: Player moved\n" +
+				"  Replied: My turn.\n" +
+				`  Ran MakeMove({"Col":2,"Row":1}): ended the interaction`,
+		},
+		{
+			name: "MultipleCommandsAndContinuation",
+			turns: []Turn{
+				{
+					RequestContent: "Go",
+					IsInitial:      true,
+					ResponseCommandCalls: []CommandCall{
+						{Name: "Move", Args: map[string]any{"Steps": 1.0}},
+						{Name: "Jump"},
+						{Name: "Move"},
+					},
+					ExecutedCommandResults: []*CommandResult{
+						{Success: true},
+						{ErrorMessage: "too high\nreally"},
+					},
+				},
+				{ResponseText: "Done."},
+			},
+			existingArchive: "Earlier summary.",
+			want: "Earlier summary.\n" +
+				"Asked: Go\n" +
+				`  Ran Move({"Steps":1}): succeeded` + "\n" +
+				"  Ran Jump(): failed: too high really\n" +
+				"  Ran Move(): not executed\n" +
+				"  Replied: Done.",
+		},
+		{
+			name: "Interrupted",
+			turns: []Turn{
+				{RequestContent: "Hello", IsInitial: true, IsInterrupted: true},
+			},
+			want: "Asked: Hello\n  Interrupted before responding",
+		},
+		{
+			name: "LongField",
+			turns: []Turn{
+				{RequestContent: strings.Repeat("好", 50), IsInitial: true},
+			},
+			want: "Asked: " + strings.Repeat("好", 40) + "...",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, want := SummarizeLocally(tt.turns, tt.existingArchive), tt.want; got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+
+	t.Run("MaxSize", func(t *testing.T) {
+		var turns []Turn
+		for i := range 200 {
+			turns = append(turns, Turn{RequestContent: strings.Repeat(string(rune('a'+i%26)), 50), IsInitial: true})
+		}
+		got := SummarizeLocally(turns, "")
+		if len(got) > localSummaryMaxSize {
+			t.Errorf("got size %d, want at most %d", len(got), localSummaryMaxSize)
+		}
+		if !strings.HasSuffix(got, "Asked: "+strings.Repeat(string(rune('a'+199%26)), 50)) {
+			t.Errorf("got %q, want the most recent line kept", got[len(got)-60:])
+		}
+	})
+
+	t.Run("LongExistingArchive", func(t *testing.T) {
+		turns := []Turn{{RequestContent: "Hello", IsInitial: true}}
+
+		// A single-line archive that fits is kept as a whole.
+		existingArchive := strings.Repeat("好", 1300)
+		got := SummarizeLocally(turns, existingArchive)
+		if want := existingArchive + "\nAsked: Hello"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+
+		// A single-line archive that is too long is cut at the end.
+		existingArchive = strings.Repeat("好", 2000)
+		got = SummarizeLocally(turns, existingArchive)
+		if len(got) > localSummaryMaxSize {
+			t.Errorf("got size %d, want at most %d", len(got), localSummaryMaxSize)
+		}
+		if !utf8.ValidString(got) {
+			t.Errorf("got invalid UTF-8 %q", got)
+		}
+		if !strings.HasPrefix(got, strings.Repeat("好", 1000)) {
+			t.Errorf("got %q, want the beginning of the existing archive kept", got[:30])
+		}
+		if !strings.HasSuffix(got, "...\nAsked: Hello") {
+			t.Errorf("got %q, want the new lines kept", got[len(got)-30:])
+		}
+	})
+}
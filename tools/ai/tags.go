@@ -0,0 +1,343 @@
+package ai
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// paramTags holds the metadata and constraints declared in the struct tags of
+// a command parameter field:
+//
+//   - name:"direction" sets the JSON name of the parameter.
+//   - desc:"..." describes the parameter.
+//   - enum:"up,down,left,right" restricts the parameter to a set of values.
+//   - min:"0" and max:"2" restrict numeric parameters to a range.
+//   - required:"true" requires the AI to always provide the parameter.
+//   - default:"1" provides a value when the AI omits the parameter.
+//
+// For slice and array parameters, enum, min and max apply to each element.
+type paramTags struct {
+	name        string
+	description string
+	required    *bool // nil if not declared
+	enum        []any
+	minimum     *float64
+	maximum     *float64
+	defaultJSON string // empty if not declared
+}
+
+// parseParamTags parses the struct tags of field. Invalid tags are reported
+// as an error and ignored in the returned paramTags.
+func parseParamTags(field reflect.StructField) (paramTags, error) {
+	tags := paramTags{
+		name:        field.Name,
+		description: field.Tag.Get("desc"),
+	}
+	if name := field.Tag.Get("name"); name != "" {
+		tags.name = name
+	}
+
+	var errs []error
+	if required, ok := field.Tag.Lookup("required"); ok {
+		if b, err := strconv.ParseBool(required); err != nil {
+			errs = append(errs, fmt.Errorf("invalid required tag %q: %w", required, err))
+		} else {
+			tags.required = &b
+		}
+	}
+
+	elemKind := constraintType(field.Type).Kind()
+	if enum, ok := field.Tag.Lookup("enum"); ok {
+		for s := range strings.SplitSeq(enum, ",") {
+			v, err := parseEnumValue(strings.TrimSpace(s), elemKind)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("invalid enum tag %q: %w", enum, err))
+				tags.enum = nil
+				break
+			}
+			tags.enum = append(tags.enum, v)
+		}
+	}
+	for _, bound := range []struct {
+		key string
+		dst **float64
+	}{
+		{"min", &tags.minimum},
+		{"max", &tags.maximum},
+	} {
+		s, ok := field.Tag.Lookup(bound.key)
+		if !ok {
+			continue
+		}
+		if !isNumericKind(elemKind) {
+			errs = append(errs, fmt.Errorf("%s tag is only supported for numeric fields", bound.key))
+			continue
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid %s tag %q: %w", bound.key, s, err))
+			continue
+		}
+		*bound.dst = &f
+	}
+
+	if def, ok := field.Tag.Lookup("default"); ok {
+		if _, err := parseDefaultValue(def, field.Type); err != nil {
+			errs = append(errs, fmt.Errorf("invalid default tag %q: %w", def, err))
+		} else {
+			tags.defaultJSON = def
+		}
+	}
+
+	if len(errs) > 0 {
+		return tags, fmt.Errorf("field %s: %w", field.Name, errors.Join(errs...))
+	}
+	return tags, nil
+}
+
+// structTags holds the parsed tags of the fields of struct types, indexed by
+// field, so that command arguments are decoded without parsing tags again.
+type structTags map[reflect.Type][]paramTags
+
+// parse parses the tags of the exported fields of typ and of all struct types
+// reachable from it, and adds them to st. Invalid tags are reported as an
+// error.
+func (st structTags) parse(typ reflect.Type) error {
+	switch typ.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Array, reflect.Map:
+		return st.parse(typ.Elem())
+	case reflect.Struct:
+	default:
+		return nil
+	}
+	if _, ok := st[typ]; ok {
+		return nil
+	}
+
+	fieldTags := make([]paramTags, typ.NumField())
+	st[typ] = fieldTags
+	var errs []error
+	fieldsByName := make(map[string]string, typ.NumField())
+	for i := range typ.NumField() {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tags, err := parseParamTags(field)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		if other, ok := fieldsByName[tags.name]; ok {
+			errs = append(errs, fmt.Errorf("field %s: duplicate parameter name %q, also used by field %s", field.Name, tags.name, other))
+		} else {
+			fieldsByName[tags.name] = field.Name
+		}
+		fieldTags[i] = tags
+		if err := st.parse(field.Type); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s: %w", typ, errors.Join(errs...))
+	}
+	return nil
+}
+
+// of returns the tags of the fields of struct type typ. Types missing from st
+// are parsed on demand, ignoring invalid tags, which is only the case for
+// default values checked while parsing.
+func (st structTags) of(typ reflect.Type) []paramTags {
+	if fieldTags, ok := st[typ]; ok {
+		return fieldTags
+	}
+	missing := structTags{}
+	missing.parse(typ)
+	return missing[typ]
+}
+
+// hasDefault reports whether a default value is declared.
+func (tags paramTags) hasDefault() bool {
+	return tags.defaultJSON != ""
+}
+
+// defaultValue returns a newly parsed default value for a field of typ.
+func (tags paramTags) defaultValue(typ reflect.Type) any {
+	v, _ := parseDefaultValue(tags.defaultJSON, typ)
+	return v
+}
+
+// isRequired reports whether the AI must always provide a value for the
+// field, as declared by the "required" tag. Omitted fields that are not
+// required are left as zero values.
+func (tags paramTags) isRequired() bool {
+	return tags.required != nil && *tags.required
+}
+
+// applyToSchema adds the declared constraints to schema, which describes a
+// field of typ.
+func (tags paramTags) applyToSchema(schema map[string]any, typ reflect.Type) {
+	if tags.description != "" {
+		schema["description"] = tags.description
+	}
+	if tags.hasDefault() {
+		schema["default"] = tags.defaultValue(typ)
+	}
+
+	target := schema
+	if items, ok := schema["items"].(map[string]any); ok {
+		target = items
+	}
+	if tags.enum != nil {
+		enum := tags.enum
+		if types, ok := target["type"].([]any); ok && slices.Contains(types, "null") {
+			// Nullable values also accept null, which must be in the enum
+			// too.
+			enum = append(slices.Clone(enum), nil)
+		}
+		target["enum"] = enum
+	}
+	if tags.minimum != nil {
+		target["minimum"] = *tags.minimum
+	}
+	if tags.maximum != nil {
+		target["maximum"] = *tags.maximum
+	}
+}
+
+// validate checks the decoded value of a field against the declared enum, min
+// and max constraints.
+func (tags paramTags) validate(fieldVal reflect.Value) error {
+	if tags.enum == nil && tags.minimum == nil && tags.maximum == nil {
+		return nil
+	}
+	for fieldVal.Kind() == reflect.Pointer {
+		if fieldVal.IsNil() {
+			return nil
+		}
+		fieldVal = fieldVal.Elem()
+	}
+	switch fieldVal.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := range fieldVal.Len() {
+			if err := tags.validate(fieldVal.Index(i)); err != nil {
+				return wrapArgPath(err, fmt.Sprintf("[%d]", i))
+			}
+		}
+		return nil
+	}
+
+	v, ok := enumValueOf(fieldVal)
+	if !ok {
+		return nil
+	}
+	if tags.enum != nil && !containsValue(tags.enum, v) {
+		values := make([]string, 0, len(tags.enum))
+		for _, e := range tags.enum {
+			values = append(values, fmt.Sprint(e))
+		}
+		return &constraintError{msg: fmt.Sprintf("got %v, want one of: %s", v, strings.Join(values, ", "))}
+	}
+	if f, isNum := v.(float64); isNum {
+		if tags.minimum != nil && f < *tags.minimum {
+			return &constraintError{msg: fmt.Sprintf("got %v, want at least %v", f, *tags.minimum)}
+		}
+		if tags.maximum != nil && f > *tags.maximum {
+			return &constraintError{msg: fmt.Sprintf("got %v, want at most %v", f, *tags.maximum)}
+		}
+	}
+	return nil
+}
+
+// constraintError reports that a command argument violates a constraint
+// declared in the struct tags of the command. Unlike other decoding errors, it
+// is reported back to the AI as a failed [CommandResult].
+type constraintError struct {
+	msg string
+}
+
+// Error implements [error].
+func (e *constraintError) Error() string {
+	return e.msg
+}
+
+// constraintType returns the type that enum, min and max constraints apply to
+// for a field of typ.
+func constraintType(typ reflect.Type) reflect.Type {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array {
+		typ = typ.Elem()
+		for typ.Kind() == reflect.Pointer {
+			typ = typ.Elem()
+		}
+	}
+	return typ
+}
+
+// parseEnumValue parses a single enum tag value for a field of the given kind.
+// Numbers are represented as float64, matching [enumValueOf].
+func parseEnumValue(s string, kind reflect.Kind) (any, error) {
+	switch {
+	case kind == reflect.String:
+		return s, nil
+	case kind == reflect.Bool:
+		return strconv.ParseBool(s)
+	case isNumericKind(kind):
+		return strconv.ParseFloat(s, 64)
+	}
+	return nil, fmt.Errorf("unsupported field kind %s", kind)
+}
+
+// enumValueOf returns the comparable representation of v used for enum, min
+// and max checks. It reports false if v is not of a supported kind.
+func enumValueOf(v reflect.Value) (any, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), true
+	case reflect.Bool:
+		return v.Bool(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return nil, false
+}
+
+// containsValue reports whether values contains v.
+func containsValue(values []any, v any) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// parseDefaultValue parses a default tag value for a field of typ. String
+// fields take the tag value verbatim, other fields take it as JSON.
+func parseDefaultValue(s string, typ reflect.Type) (any, error) {
+	var v any
+	if constraintType(typ).Kind() == reflect.String && typ.Kind() != reflect.Slice && typ.Kind() != reflect.Array {
+		v = s
+	} else if err := json.Unmarshal([]byte(s), &v); err != nil {
+		return nil, err
+	}
+	if err := setField("default", reflect.New(typ).Elem(), reflect.ValueOf(v), nil); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// isNumericKind reports whether the kind is one of the integer or float types.
+func isNumericKind(kind reflect.Kind) bool {
+	return isIntKind(kind) || kind == reflect.Float32 || kind == reflect.Float64
+}
@@ -0,0 +1,360 @@
+package ai
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type TaggedMoveCmd struct {
+	Direction string   `name:"direction" desc:"Where to go" enum:"up,down,left,right"`
+	Steps     int      `name:"steps" min:"1" max:"3" default:"1"`
+	Cells     []int    `name:"cells" min:"0" max:"8"`
+	Note      *string  `name:"note" required:"true"`
+	Speed     *float64 `enum:"0.5, 1, 2"`
+}
+
+func TestParseParamTags(t *testing.T) {
+	for _, tt := range []struct {
+		name          string
+		field         reflect.StructField
+		wantTags      paramTags
+		wantErrSubstr string
+	}{
+		{
+			name:     "NoTags",
+			field:    reflect.StructField{Name: "Value", Type: reflect.TypeFor[int]()},
+			wantTags: paramTags{name: "Value"},
+		},
+		{
+			name: "AllTags",
+			field: reflect.StructField{
+				Name: "Steps",
+				Type: reflect.TypeFor[int](),
+				Tag:  `name:"steps" desc:"How far" enum:"1,2,3" min:"1" max:"3" required:"false" default:"2"`,
+			},
+			wantTags: paramTags{
+				name:        "steps",
+				description: "How far",
+				required:    new(bool),
+				enum:        []any{1.0, 2.0, 3.0},
+				minimum:     ptrTo(1.0),
+				maximum:     ptrTo(3.0),
+				defaultJSON: "2",
+			},
+		},
+		{
+			name:     "StringEnum",
+			field:    reflect.StructField{Name: "Dir", Type: reflect.TypeFor[[]string](), Tag: `enum:"up, down"`},
+			wantTags: paramTags{name: "Dir", enum: []any{"up", "down"}},
+		},
+		{
+			name:          "InvalidMin",
+			field:         reflect.StructField{Name: "Steps", Type: reflect.TypeFor[int](), Tag: `min:"one" max:"3"`},
+			wantTags:      paramTags{name: "Steps", maximum: ptrTo(3.0)},
+			wantErrSubstr: `field Steps: invalid min tag "one"`,
+		},
+		{
+			name:          "MinOnString",
+			field:         reflect.StructField{Name: "Dir", Type: reflect.TypeFor[string](), Tag: `min:"1"`},
+			wantTags:      paramTags{name: "Dir"},
+			wantErrSubstr: "min tag is only supported for numeric fields",
+		},
+		{
+			name:          "InvalidEnum",
+			field:         reflect.StructField{Name: "Steps", Type: reflect.TypeFor[int](), Tag: `enum:"1,two"`},
+			wantTags:      paramTags{name: "Steps"},
+			wantErrSubstr: `invalid enum tag "1,two"`,
+		},
+		{
+			name:          "InvalidDefault",
+			field:         reflect.StructField{Name: "Steps", Type: reflect.TypeFor[int](), Tag: `default:"\"one\""`},
+			wantTags:      paramTags{name: "Steps"},
+			wantErrSubstr: "type mismatch: got string, want int",
+		},
+		{
+			name:          "InvalidRequired",
+			field:         reflect.StructField{Name: "Steps", Type: reflect.TypeFor[int](), Tag: `required:"yes"`},
+			wantTags:      paramTags{name: "Steps"},
+			wantErrSubstr: `invalid required tag "yes"`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			tags, err := parseParamTags(tt.field)
+			if tt.wantErrSubstr != "" {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				if got, wantSubstr := err.Error(), tt.wantErrSubstr; !strings.Contains(got, wantSubstr) {
+					t.Errorf("got %q, want substring %q", got, wantSubstr)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if got, want := tags, tt.wantTags; !reflect.DeepEqual(got, want) {
+				t.Errorf("got %#v, want %#v", got, want)
+			}
+		})
+	}
+}
+
+func TestExtractCommandSpecTags(t *testing.T) {
+	spec := extractCommandSpec(reflect.TypeFor[TaggedMoveCmd](), nil)
+	want := []CommandParamSpec{
+		{
+			Name:        "direction",
+			Type:        "string",
+			Description: "Where to go",
+			Enum:        []any{"up", "down", "left", "right"},
+			Schema: map[string]any{
+				"type":        "string",
+				"description": "Where to go",
+				"enum":        []any{"up", "down", "left", "right"},
+			},
+		},
+		{
+			Name:    "steps",
+			Type:    "int",
+			Minimum: ptrTo(1.0),
+			Maximum: ptrTo(3.0),
+			Default: 1.0,
+			Schema: map[string]any{
+				"type":    "integer",
+				"minimum": 1.0,
+				"maximum": 3.0,
+				"default": 1.0,
+			},
+		},
+		{
+			Name:    "cells",
+			Type:    "[]int",
+			Minimum: ptrTo(0.0),
+			Maximum: ptrTo(8.0),
+			Schema: map[string]any{
+				"type":  "array",
+				"items": map[string]any{"type": "integer", "minimum": 0.0, "maximum": 8.0},
+			},
+		},
+		{
+			Name:     "note",
+			Type:     "*string",
+			Required: true,
+			Schema:   map[string]any{"type": []any{"string", "null"}},
+		},
+		{
+			Name: "Speed",
+			Type: "*float64",
+			Enum: []any{0.5, 1.0, 2.0},
+			Schema: map[string]any{
+				"type": []any{"number", "null"},
+				"enum": []any{0.5, 1.0, 2.0, nil},
+			},
+		},
+	}
+	if got := spec.Parameters; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+	if got, want := spec.ParametersSchema()["required"], []any{"note"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+}
+
+func TestNewCommandInfo(t *testing.T) {
+	type BadTarget struct {
+		Row int `min:"low"`
+	}
+	type BadCmd struct {
+		Steps int `required:"yes"`
+	}
+	type NestedBadCmd struct {
+		Targets []BadTarget
+	}
+	type DuplicateNameCmd struct {
+		Steps int
+		Count int `name:"Steps"`
+	}
+
+	for _, tt := range []struct {
+		name          string
+		typ           reflect.Type
+		wantErrSubstr string
+	}{
+		{
+			name: "Valid",
+			typ:  reflect.TypeFor[TaggedMoveCmd](),
+		},
+		{
+			name:          "InvalidTag",
+			typ:           reflect.TypeFor[BadCmd](),
+			wantErrSubstr: `field Steps: invalid required tag "yes"`,
+		},
+		{
+			name:          "InvalidNestedTag",
+			typ:           reflect.TypeFor[NestedBadCmd](),
+			wantErrSubstr: `field Row: invalid min tag "low"`,
+		},
+		{
+			name:          "DuplicateName",
+			typ:           reflect.TypeFor[DuplicateNameCmd](),
+			wantErrSubstr: `field Count: duplicate parameter name "Steps", also used by field Steps`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := newCommandInfo(tt.typ, nil)
+			if tt.wantErrSubstr != "" {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				if got := err.Error(); !strings.Contains(got, tt.wantErrSubstr) {
+					t.Errorf("got %q, want substring %q", got, tt.wantErrSubstr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if got, want := info.spec, extractCommandSpec(tt.typ, nil); !reflect.DeepEqual(got, want) {
+				t.Errorf("got %#v, want %#v", got, want)
+			}
+			if _, ok := info.tags[tt.typ]; !ok {
+				t.Errorf("expected tags of %s to be cached", tt.typ)
+			}
+		})
+	}
+
+	t.Run("PanicsOnRegistration", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("expected panic")
+			}
+		}()
+		XGot_Player_XGox_OnCmd(&Player{}, func(cmd BadCmd) error { return nil })
+	})
+}
+
+func TestCallCommandHandlerConstraints(t *testing.T) {
+	var gotCmd TaggedMoveCmd
+	info := commandInfo{
+		typ: reflect.TypeFor[TaggedMoveCmd](),
+		handler: func(cmd TaggedMoveCmd) error {
+			gotCmd = cmd
+			return nil
+		},
+		spec: extractCommandSpec(reflect.TypeFor[TaggedMoveCmd](), nil),
+	}
+	note := "hi"
+
+	for _, tt := range []struct {
+		name       string
+		args       map[string]any
+		wantResult *CommandResult
+		wantCmd    TaggedMoveCmd
+	}{
+		{
+			name:       "Valid",
+			args:       map[string]any{"direction": "up", "steps": 3.0, "cells": []any{0.0, 8.0}, "note": "hi"},
+			wantResult: &CommandResult{Success: true},
+			wantCmd:    TaggedMoveCmd{Direction: "up", Steps: 3, Cells: []int{0, 8}, Note: &note},
+		},
+		{
+			name:       "NullTreatedAsAbsent",
+			args:       map[string]any{"direction": "left", "steps": nil, "note": "hi"},
+			wantResult: &CommandResult{Success: true},
+			wantCmd:    TaggedMoveCmd{Direction: "left", Steps: 1, Note: &note},
+		},
+		{
+			name:       "NullRequired",
+			args:       map[string]any{"direction": "left", "note": nil},
+			wantResult: &CommandResult{ErrorMessage: "invalid arguments: field note: missing required value"},
+		},
+		{
+			name:       "DefaultAppliedWhenOmitted",
+			args:       map[string]any{"direction": "left", "note": "hi"},
+			wantResult: &CommandResult{Success: true},
+			wantCmd:    TaggedMoveCmd{Direction: "left", Steps: 1, Note: &note},
+		},
+		{
+			name:       "GoFieldNameIgnored",
+			args:       map[string]any{"Direction": "up", "note": "hi"},
+			wantResult: &CommandResult{Success: true},
+			wantCmd:    TaggedMoveCmd{Steps: 1, Note: &note},
+		},
+		{
+			name:       "EnumViolation",
+			args:       map[string]any{"direction": "north", "note": "hi"},
+			wantResult: &CommandResult{ErrorMessage: "invalid arguments: field direction: got north, want one of: up, down, left, right"},
+		},
+		{
+			name:       "NumericEnumViolation",
+			args:       map[string]any{"note": "hi", "Speed": 1.5},
+			wantResult: &CommandResult{ErrorMessage: "invalid arguments: field Speed: got 1.5, want one of: 0.5, 1, 2"},
+		},
+		{
+			name:       "NullableEnumNull",
+			args:       map[string]any{"note": "hi", "Speed": nil},
+			wantResult: &CommandResult{Success: true},
+			wantCmd:    TaggedMoveCmd{Steps: 1, Note: &note},
+		},
+		{
+			name:       "MinViolation",
+			args:       map[string]any{"steps": 0.0, "note": "hi"},
+			wantResult: &CommandResult{ErrorMessage: "invalid arguments: field steps: got 0, want at least 1"},
+		},
+		{
+			name:       "ElementMaxViolation",
+			args:       map[string]any{"cells": []any{1.0, 9.0}, "note": "hi"},
+			wantResult: &CommandResult{ErrorMessage: "invalid arguments: field cells[1]: got 9, want at most 8"},
+		},
+		{
+			name:       "MissingRequired",
+			args:       map[string]any{"direction": "up"},
+			wantResult: &CommandResult{ErrorMessage: "invalid arguments: field note: missing required value"},
+		},
+		{
+			name:       "NilArgs",
+			wantResult: &CommandResult{ErrorMessage: "invalid arguments: field note: missing required value"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			gotCmd = TaggedMoveCmd{}
+			result, err := callCommandHandler(SpxExecutor{}, nil, info, tt.args)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if got, want := result, tt.wantResult; !reflect.DeepEqual(got, want) {
+				t.Errorf("got %#v, want %#v", got, want)
+			}
+			if got, want := gotCmd, tt.wantCmd; !reflect.DeepEqual(got, want) {
+				t.Errorf("got %#v, want %#v", got, want)
+			}
+		})
+	}
+}
+
+func TestPopulateCommandFieldsNestedConstraints(t *testing.T) {
+	type Target struct {
+		Row int `name:"row" min:"0" max:"2"`
+		Col int `name:"col" min:"0" max:"2"`
+	}
+	type PlaceCmd struct {
+		Targets []Target `name:"targets"`
+	}
+
+	var cmd PlaceCmd
+	err := populateCommandFields(reflect.ValueOf(&cmd).Elem(), map[string]any{
+		"targets": []any{
+			map[string]any{"row": 1.0, "col": 1.0},
+			map[string]any{"row": 1.0, "col": 3.0},
+		},
+	}, nil)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if got, want := err.Error(), "field targets[1].col: got 3, want at most 2"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func ptrTo[T any](v T) *T {
+	return &v
+}
@@ -20,6 +20,18 @@ type Transport interface {
 	Archive(ctx context.Context, turns []Turn, existingArchive string) (ArchivedHistory, error)
 }
 
+// StreamingTransport is an optional interface that a [Transport] can implement
+// to deliver the AI's response incrementally.
+type StreamingTransport interface {
+	Transport
+
+	// InteractStream is like [Transport.Interact], but calls onText with each
+	// incremental fragment of [Response.Text] as soon as it arrives. The
+	// returned [Response] is complete, including the full text and the
+	// command.
+	InteractStream(ctx context.Context, req Request, onText func(delta string)) (Response, error)
+}
+
 // Request encapsulates all information sent to the AI via the [Transport].
 type Request struct {
 	// Content is the core user input.
@@ -66,6 +78,31 @@ type Response struct {
 
 	// CommandArgs holds the arguments for the command to be executed.
 	CommandArgs map[string]any `json:"commandArgs,omitempty"`
+
+	// CommandCalls optionally lists multiple commands to be executed in order.
+	// If not empty, it takes precedence over CommandName and CommandArgs.
+	CommandCalls []CommandCall `json:"commandCalls,omitempty"`
+}
+
+// commandCalls returns the commands to be executed in order, taking both
+// CommandCalls and the single-command fields into account.
+func (r Response) commandCalls() []CommandCall {
+	if len(r.CommandCalls) > 0 {
+		return r.CommandCalls
+	}
+	if r.CommandName != "" {
+		return []CommandCall{{Name: r.CommandName, Args: r.CommandArgs}}
+	}
+	return nil
+}
+
+// CommandCall represents a single command call requested by the AI.
+type CommandCall struct {
+	// Name is the name of the command to execute.
+	Name string `json:"name"`
+
+	// Args holds the arguments for the command to be executed.
+	Args map[string]any `json:"args,omitempty"`
 }
 
 // Turn represents a single turn in the conversation history.
@@ -90,9 +127,24 @@ type Turn struct {
 	// recording.
 	ExecutedCommandResult *CommandResult `json:"executedCommandResult,omitempty"`
 
+	// ResponseCommandCalls lists the commands requested by the AI in this
+	// turn's response when it requested more than one. In that case,
+	// ResponseCommandName, ResponseCommandArgs and ExecutedCommandResult
+	// describe the first one for backward compatibility.
+	ResponseCommandCalls []CommandCall `json:"responseCommandCalls,omitempty"`
+
+	// ExecutedCommandResults holds the results of executing ResponseCommandCalls
+	// in order. Execution stops at the first command that returns [Break] or
+	// fails, so it may be shorter than ResponseCommandCalls.
+	ExecutedCommandResults []*CommandResult `json:"executedCommandResults,omitempty"`
+
 	// IsInitial indicates whether this turn is the initial turn of an
 	// interaction sequence (i.e., ContinuationTurn == 0).
 	IsInitial bool `json:"isInitial,omitempty"`
+
+	// IsInterrupted indicates whether the interaction sequence was interrupted
+	// (e.g., canceled by the game) before the AI responded in this turn.
+	IsInterrupted bool `json:"isInterrupted,omitempty"`
 }
 
 // ArchivedHistory contains information about archived historical interactions.
@@ -141,6 +193,53 @@ func SetDefaultTransport(t Transport) {
 	defaultTransport = t
 }
 
+// StatusError represents a transport-level HTTP error, returned when the
+// backend responds with a non-OK status.
+type StatusError struct {
+	// StatusCode is the HTTP status code, e.g., 500.
+	StatusCode int
+
+	// Status is the HTTP status text, e.g., "Internal Server Error".
+	Status string
+
+	// Body is the response body, which usually describes the error.
+	Body string
+}
+
+// Error implements [error].
+func (se *StatusError) Error() string {
+	if se.Body != "" {
+		return fmt.Sprintf("failed to fetch with status %d %s: %s", se.StatusCode, se.Status, se.Body)
+	}
+	return fmt.Sprintf("failed to fetch with status %d %s", se.StatusCode, se.Status)
+}
+
+// StreamError represents an error event sent by the backend in a streamed
+// response after the response already started.
+//
+// Transports report it wrapped in [TooManyRequestsError] or
+// [QuotaExceededError] if Reason is [StreamErrorReasonRateLimited] or
+// [StreamErrorReasonQuotaExceeded].
+type StreamError struct {
+	// Reason is the machine-readable reason of the error, e.g.,
+	// "streamFailed".
+	Reason string
+
+	// Message is the human-readable description of the error.
+	Message string
+}
+
+// Reasons of [StreamError] with special meaning.
+const (
+	StreamErrorReasonRateLimited   = "rateLimited"
+	StreamErrorReasonQuotaExceeded = "quotaExceeded"
+)
+
+// Error implements [error].
+func (se *StreamError) Error() string {
+	return fmt.Sprintf("stream failed (%s): %s", se.Reason, se.Message)
+}
+
 // TooManyRequestsError represents a transport-level HTTP 429 error.
 type TooManyRequestsError struct {
 	RetryAfter time.Duration
@@ -166,6 +265,52 @@ func (tmr *TooManyRequestsError) Unwrap() error {
 	return tmr.Err
 }
 
+// QuotaExceededError represents a transport-level HTTP 403 error returned
+// with a Retry-After header when the long-window quota of the user is
+// exhausted. Unlike [TooManyRequestsError], retrying before RetryAfter elapses
+// is pointless, so interactions fail right away.
+type QuotaExceededError struct {
+	RetryAfter time.Duration
+	Err        error
+}
+
+// Error implements [error].
+func (qe *QuotaExceededError) Error() string {
+	if qe.RetryAfter > 0 {
+		if qe.Err != nil {
+			return fmt.Sprintf("quota exceeded (retry after %s): %v", qe.RetryAfter, qe.Err)
+		}
+		return fmt.Sprintf("quota exceeded (retry after %s)", qe.RetryAfter)
+	}
+	if qe.Err != nil {
+		return fmt.Sprintf("quota exceeded: %v", qe.Err)
+	}
+	return "quota exceeded"
+}
+
+// Unwrap returns the underlying error.
+func (qe *QuotaExceededError) Unwrap() error {
+	return qe.Err
+}
+
+// IsQuotaExceeded reports whether err is caused by an exhausted quota, in
+// which case the AI cannot be used until [QuotaRetryAfter] elapses.
+func IsQuotaExceeded(err error) bool {
+	var qeErr *QuotaExceededError
+	return errors.As(err, &qeErr)
+}
+
+// QuotaRetryAfter returns how long to wait before the AI can be used again if
+// err is caused by an exhausted quota. It returns 0 otherwise, or if the wait
+// time is unknown.
+func QuotaRetryAfter(err error) time.Duration {
+	var qeErr *QuotaExceededError
+	if !errors.As(err, &qeErr) {
+		return 0
+	}
+	return qeErr.RetryAfter
+}
+
 // RetryAfterFromHeader converts a Retry-After header value to [time.Duration].
 func RetryAfterFromHeader(value string) time.Duration {
 	value = strings.TrimSpace(value)
@@ -211,47 +356,3 @@ func parseHTTPTime(text string) (t time.Time, err error) {
 	}
 	return
 }
-
-// rateLimitGate coordinates Retry-After windows so callers wait until the
-// backend allows another attempt.
-type rateLimitGate struct {
-	nextAllowed time.Time
-}
-
-// Wait blocks until the gate opens or the provided context finishes. It returns
-// nil when waiting is not required or the wait completes. Otherwise it returns
-// ctx.Err().
-func (rlg *rateLimitGate) Wait(ctx context.Context) error {
-	wait := time.Until(rlg.nextAllowed)
-	if wait <= 0 {
-		return nil
-	}
-
-	timer := time.NewTimer(wait)
-	defer timer.Stop()
-
-	select {
-	case <-timer.C:
-		return nil
-	case <-ctx.Done():
-		return ctx.Err()
-	}
-}
-
-// Observe records rate limiting hints from errors returned by the [Transport].
-func (rlg *rateLimitGate) Observe(err error) {
-	var tmrErr *TooManyRequestsError
-	if !errors.As(err, &tmrErr) {
-		return
-	}
-
-	delay := tmrErr.RetryAfter
-	if delay <= 0 {
-		delay = time.Second
-	}
-
-	retryAt := time.Now().Add(delay)
-	if retryAt.After(rlg.nextAllowed) {
-		rlg.nextAllowed = retryAt
-	}
-}
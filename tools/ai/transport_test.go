@@ -3,6 +3,7 @@ package ai
 import (
 	"context"
 	"errors"
+	"fmt"
 	"reflect"
 	"testing"
 	"time"
@@ -97,6 +98,35 @@ func TestTooManyRequestsError(t *testing.T) {
 	}
 }
 
+func TestQuotaExceededError(t *testing.T) {
+	baseErr := errors.New("failed to fetch with status: 403 Forbidden")
+	var err error = fmt.Errorf("ai interaction failed after 1 transport attempts: %w", &QuotaExceededError{
+		RetryAfter: 30 * time.Minute,
+		Err:        baseErr,
+	})
+
+	if got, want := err.Error(), "ai interaction failed after 1 transport attempts: quota exceeded (retry after 30m0s): failed to fetch with status: 403 Forbidden"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := IsQuotaExceeded(err), true; got != want {
+		t.Errorf("got %t, want %t", got, want)
+	}
+	if got, want := QuotaRetryAfter(err), 30*time.Minute; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := errors.Is(err, baseErr), true; got != want {
+		t.Errorf("got %t, want %t", got, want)
+	}
+
+	tmrErr := &TooManyRequestsError{RetryAfter: time.Second}
+	if got, want := IsQuotaExceeded(tmrErr), false; got != want {
+		t.Errorf("got %t, want %t", got, want)
+	}
+	if got, want := QuotaRetryAfter(tmrErr), time.Duration(0); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
 func TestRetryAfterFromHeader(t *testing.T) {
 	t.Run("SecondsValue", func(t *testing.T) {
 		got := RetryAfterFromHeader("42")
@@ -133,65 +163,11 @@ func TestRetryAfterFromHeader(t *testing.T) {
 	})
 }
 
-func TestRateLimitGate(t *testing.T) {
-	t.Run("ObserveSetsNextAllowed", func(t *testing.T) {
-		var rateGate rateLimitGate
-		start := time.Now()
-		rateGate.Observe(&TooManyRequestsError{RetryAfter: 25 * time.Millisecond})
-
-		expectedMin := start.Add(25 * time.Millisecond)
-		if rateGate.nextAllowed.Before(expectedMin) {
-			t.Errorf("nextAllowed %v before expected minimum %v", rateGate.nextAllowed, expectedMin)
-		}
-	})
-
-	t.Run("ObserveKeepsLaterDeadline", func(t *testing.T) {
-		initial := time.Now().Add(200 * time.Millisecond)
-		rateGate := rateLimitGate{nextAllowed: initial}
-
-		rateGate.Observe(&TooManyRequestsError{RetryAfter: 10 * time.Millisecond})
-		if rateGate.nextAllowed != initial {
-			t.Errorf("nextAllowed %v changed from %v", rateGate.nextAllowed, initial)
-		}
-	})
-
-	t.Run("ObserveIgnoresNonRateErrors", func(t *testing.T) {
-		var rateGate rateLimitGate
-		rateGate.Observe(errors.New("other"))
-		if !rateGate.nextAllowed.IsZero() {
-			t.Errorf("nextAllowed %v, want zero time", rateGate.nextAllowed)
-		}
-	})
-
-	t.Run("WaitBlocksUntilAllowed", func(t *testing.T) {
-		delay := 30 * time.Millisecond
-		rateGate := rateLimitGate{nextAllowed: time.Now().Add(delay)}
-		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-		t.Cleanup(cancel)
-		start := time.Now()
-
-		if err := rateGate.Wait(ctx); err != nil {
-			t.Fatalf("wait returned error: %v", err)
-		}
-		if elapsed := time.Since(start); elapsed < delay {
-			t.Fatalf("wait returned too early: elapsed %v, want at least %v", elapsed, delay)
-		}
-	})
-
-	t.Run("WaitReturnsImmediatelyWhenAllowed", func(t *testing.T) {
-		var rateGate rateLimitGate
-		if err := rateGate.Wait(context.Background()); err != nil {
-			t.Fatalf("wait returned error: %v", err)
-		}
-	})
-
-	t.Run("WaitRespectsContextTimeout", func(t *testing.T) {
-		rateGate := rateLimitGate{nextAllowed: time.Now().Add(time.Second)}
-		ctx, cancel := context.WithTimeout(context.Background(), 25*time.Millisecond)
-		t.Cleanup(cancel)
+type mockStreamingTransport struct {
+	mockTransport
+	InteractStreamFunc func(ctx context.Context, req Request, onText func(delta string)) (Response, error)
+}
 
-		if err := rateGate.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
-			t.Fatalf("expected deadline exceeded, got %v", err)
-		}
-	})
+func (m *mockStreamingTransport) InteractStream(ctx context.Context, req Request, onText func(delta string)) (Response, error) {
+	return m.InteractStreamFunc(ctx, req, onText)
 }
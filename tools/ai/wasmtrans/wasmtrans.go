@@ -8,10 +8,13 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"mime"
 	"syscall/js"
 	"time"
 
 	"github.com/goplus/builder/tools/ai"
+	"github.com/goplus/builder/tools/ai/internal/sse"
 )
 
 // wasmTransport implements [ai.Transport] using JavaScript's fetch API.
@@ -47,6 +50,9 @@ func WithTokenProvider(provider func() string) Option {
 // New creates a new [ai.Transport] suitable for Wasm environments. It uses
 // JavaScript interop (syscall/js) to make network requests. By default, it uses
 // "/api/ai-interaction" endpoint and sends no Authorization token.
+//
+// The returned [ai.Transport] also implements [ai.StreamingTransport] using
+// Server-Sent Events read via ReadableStream.
 func New(opts ...Option) ai.Transport {
 	t := &wasmTransport{
 		endpoint:      "/api/ai-interaction",
@@ -72,6 +78,42 @@ func (t *wasmTransport) Interact(ctx context.Context, req ai.Request) (ai.Respon
 	return resp, nil
 }
 
+// InteractStream implements [ai.StreamingTransport].
+func (t *wasmTransport) InteractStream(ctx context.Context, req ai.Request, onText func(delta string)) (ai.Response, error) {
+	reqBody, err := json.Marshal(req)
+	if err != nil {
+		return ai.Response{}, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	var resp ai.Response
+	if err := t.fetch(ctx, "/turns", reqBody, "text/event-stream, application/json", func(jsResp js.Value) error {
+		contentType := jsResp.Get("headers").Call("get", "Content-Type")
+		if mediaType, _, _ := mime.ParseMediaType(contentType.String()); !contentType.Truthy() || mediaType != "text/event-stream" {
+			// Fall back to a regular response if the server does not support streaming.
+			if err := parseJSON(ctx, jsResp, &resp); err != nil {
+				return err
+			}
+			if resp.Text != "" {
+				onText(resp.Text)
+			}
+			return nil
+		}
+
+		jsReader := jsResp.Get("body").Call("getReader")
+		defer jsReader.Call("releaseLock")
+
+		var err error
+		resp, err = sse.ReadResponse(&streamReader{ctx: ctx, reader: jsReader}, onText)
+		if err != nil {
+			jsReader.Call("cancel")
+		}
+		return err
+	}); err != nil {
+		return ai.Response{}, err
+	}
+	return resp, nil
+}
+
 // Archive implements [ai.Transport].
 func (t *wasmTransport) Archive(ctx context.Context, turns []ai.Turn, existingArchive string) (ai.ArchivedHistory, error) {
 	reqBody, err := json.Marshal(map[string]any{
@@ -104,7 +146,30 @@ func (t *wasmTransport) buildHeaders() map[string]any {
 
 // fetchAndParse performs a fetch request and parses the JSON response into the target.
 func (t *wasmTransport) fetchAndParse(ctx context.Context, path string, body []byte, result any) error {
+	return t.fetch(ctx, path, body, "application/json", func(jsResp js.Value) error {
+		return parseJSON(ctx, jsResp, result)
+	})
+}
+
+// parseJSON parses the JSON body of a fetch response into the target.
+func parseJSON(ctx context.Context, jsResp js.Value, result any) error {
+	jsJSON, err := awaitPromise(ctx, jsResp.Call("json"))
+	if err != nil {
+		return fmt.Errorf("failed to process json response: %w", err)
+	}
+	jsonString := js.Global().Get("JSON").Call("stringify", jsJSON).String()
+
+	if err := json.Unmarshal([]byte(jsonString), result); err != nil {
+		return fmt.Errorf("%w: failed to unmarshal response json: %w", ai.ErrInvalidResponse, err)
+	}
+	return nil
+}
+
+// fetch performs a fetch request and calls handle with the successful
+// response. The request stays abortable via ctx until handle returns.
+func (t *wasmTransport) fetch(ctx context.Context, path string, body []byte, accept string, handle func(jsResp js.Value) error) error {
 	headers := t.buildHeaders()
+	headers["Accept"] = accept
 
 	jsAbortController := js.Global().Get("AbortController").New()
 	defer context.AfterFunc(ctx, func() {
@@ -126,44 +191,74 @@ func (t *wasmTransport) fetchAndParse(ctx context.Context, path string, body []b
 		status := jsResp.Get("status").Int()
 		statusText := jsResp.Get("statusText").String()
 		retryAfter := time.Duration(0)
+		hasRetryAfter := false
 		if headers := jsResp.Get("headers"); headers.Truthy() {
 			headerValue := headers.Call("get", "Retry-After")
 			if headerValue.Truthy() {
 				retryAfter = ai.RetryAfterFromHeader(headerValue.String())
+				hasRetryAfter = true
 			}
 		}
 
+		statusErr := &ai.StatusError{
+			StatusCode: status,
+			Status:     statusText,
+		}
 		bodyPromise := jsResp.Call("text")
 		bodyTextVal, bodyErr := awaitPromise(ctx, bodyPromise)
 		if bodyErr != nil {
-			err := fmt.Errorf("failed to fetch with status %d %s (and failed to read error body: %w)", status, statusText, bodyErr)
-			if status == 429 {
-				return &ai.TooManyRequestsError{
-					RetryAfter: retryAfter,
-					Err:        err,
-				}
-			}
-			return err
+			err := fmt.Errorf("%w (and failed to read error body: %w)", statusErr, bodyErr)
+			return statusError(status, hasRetryAfter, retryAfter, err)
 		}
 
-		bodyText := bodyTextVal.String()
-		if status == 429 {
-			return &ai.TooManyRequestsError{
-				RetryAfter: retryAfter,
-				Err:        fmt.Errorf("failed to fetch with status %d %s: %s", status, statusText, bodyText),
-			}
-		}
-		return fmt.Errorf("failed to fetch with status %d %s: %s", status, statusText, bodyText)
+		statusErr.Body = bodyTextVal.String()
+		return statusError(status, hasRetryAfter, retryAfter, statusErr)
 	}
 
-	jsJSON, err := awaitPromise(ctx, jsResp.Call("json"))
-	if err != nil {
-		return fmt.Errorf("failed to process json response: %w", err)
+	return handle(jsResp)
+}
+
+// statusError wraps err, which wraps the [ai.StatusError] of a non-OK
+// response with status, in the typed error for rate limiting or quota
+// exhaustion if applicable.
+func statusError(status int, hasRetryAfter bool, retryAfter time.Duration, err error) error {
+	switch {
+	case status == 429:
+		return &ai.TooManyRequestsError{
+			RetryAfter: retryAfter,
+			Err:        err,
+		}
+	case status == 403 && hasRetryAfter:
+		return &ai.QuotaExceededError{
+			RetryAfter: retryAfter,
+			Err:        err,
+		}
 	}
-	jsonString := js.Global().Get("JSON").Call("stringify", jsJSON).String()
+	return err
+}
 
-	if err := json.Unmarshal([]byte(jsonString), result); err != nil {
-		return fmt.Errorf("failed to unmarshal response json: %w", err)
+// streamReader adapts a JavaScript ReadableStreamDefaultReader to [io.Reader].
+type streamReader struct {
+	ctx    context.Context
+	reader js.Value
+	buf    []byte
+}
+
+// Read implements [io.Reader].
+func (r *streamReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		result, err := awaitPromise(r.ctx, r.reader.Call("read"))
+		if err != nil {
+			return 0, err
+		}
+		if result.Get("done").Bool() {
+			return 0, io.EOF
+		}
+		chunk := result.Get("value")
+		r.buf = make([]byte, chunk.Get("length").Int())
+		js.CopyBytesToGo(r.buf, chunk)
 	}
-	return nil
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
 }
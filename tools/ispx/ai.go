@@ -17,6 +17,9 @@ func init() {
 	js.Global().Set("xbuilder_set_ai_description", js.FuncOf(setAIDescription))
 	js.Global().Set("xbuilder_set_ai_interaction_api_endpoint", js.FuncOf(setAIInteractionAPIEndpoint))
 	js.Global().Set("xbuilder_set_ai_interaction_api_token_provider", js.FuncOf(setAIInteractionAPITokenProvider))
+	js.Global().Set("xbuilder_set_ai_memory_namespace", js.FuncOf(setAIMemoryNamespace))
+
+	ai.SetDefaultMemoryStore(&localStorageMemoryStore{})
 }
 
 // initAI initializes AI integration for the ispx interpreter.
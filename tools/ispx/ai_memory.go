@@ -0,0 +1,99 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"syscall/js"
+
+	"github.com/goplus/builder/tools/ai"
+)
+
+// aiMemoryKeyPrefix is the prefix of localStorage keys holding AI player
+// memory snapshots.
+const aiMemoryKeyPrefix = "xbuilder_ai_memory:"
+
+// aiMemoryNamespace holds the namespace that isolates AI player memory of
+// different projects sharing the same localStorage. It is empty for projects
+// without a stable key, such as unsaved ones, whose memory is not persisted.
+var aiMemoryNamespace string
+
+// setAIMemoryNamespace sets [aiMemoryNamespace] from JavaScript.
+func setAIMemoryNamespace(this js.Value, args []js.Value) any {
+	aiMemoryNamespace = ""
+	if len(args) > 0 {
+		aiMemoryNamespace = args[0].String()
+	}
+	return nil
+}
+
+// localStorageMemoryStore implements [ai.MemoryStore] using the browser's
+// localStorage.
+type localStorageMemoryStore struct{}
+
+// storageKey returns the localStorage key for key. It reports an error if
+// [aiMemoryNamespace] is empty, so projects without a stable key don't share
+// their memory.
+func (s *localStorageMemoryStore) storageKey(key string) (string, error) {
+	if aiMemoryNamespace == "" {
+		return "", fmt.Errorf("%w: project has no stable key for AI memory, save it first", ai.ErrMemoryStoreNotSet)
+	}
+	return aiMemoryKeyPrefix + aiMemoryNamespace + ":" + key, nil
+}
+
+// call calls method on localStorage with args. It converts JavaScript
+// exceptions, such as those thrown when localStorage is unavailable or its
+// quota is exceeded, into errors.
+func (s *localStorageMemoryStore) call(method string, args ...any) (result js.Value, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if jsErr, ok := r.(js.Error); ok {
+				err = fmt.Errorf("localStorage.%s failed: %w", method, jsErr)
+				return
+			}
+			panic(r)
+		}
+	}()
+	localStorage := js.Global().Get("localStorage")
+	if localStorage.IsUndefined() || localStorage.IsNull() {
+		return js.Undefined(), fmt.Errorf("localStorage is not available")
+	}
+	return localStorage.Call(method, args...), nil
+}
+
+// Load implements [ai.MemoryStore].
+func (s *localStorageMemoryStore) Load(ctx context.Context, key string) ([]byte, error) {
+	storageKey, err := s.storageKey(key)
+	if err != nil {
+		return nil, err
+	}
+	item, err := s.call("getItem", storageKey)
+	if err != nil {
+		return nil, err
+	}
+	if item.IsNull() {
+		return nil, fmt.Errorf("%w: %s", ai.ErrMemoryNotFound, key)
+	}
+	return []byte(item.String()), nil
+}
+
+// Save implements [ai.MemoryStore].
+func (s *localStorageMemoryStore) Save(ctx context.Context, key string, data []byte) error {
+	storageKey, err := s.storageKey(key)
+	if err != nil {
+		return err
+	}
+	_, err = s.call("setItem", storageKey, string(data))
+	return err
+}
+
+// Delete implements [ai.MemoryStore].
+func (s *localStorageMemoryStore) Delete(ctx context.Context, key string) error {
+	storageKey, err := s.storageKey(key)
+	if err != nil {
+		return err
+	}
+	_, err = s.call("removeItem", storageKey)
+	return err
+}
@@ -16,12 +16,15 @@ func init() {
 		Name: "ai",
 		Path: "github.com/goplus/builder/tools/ai",
 		Deps: map[string]string{
+			"cmp":                              "cmp",
 			"context":                          "context",
+			"encoding/json":                    "json",
 			"errors":                           "errors",
 			"fmt":                              "fmt",
 			"github.com/goplus/spx/v2/pkg/spx": "spx",
 			"iter":                             "iter",
 			"log":                              "log",
+			"maps":                             "maps",
 			"math":                             "math",
 			"math/rand/v2":                     "rand",
 			"reflect":                          "reflect",
@@ -30,37 +33,99 @@ func init() {
 			"strings":                          "strings",
 			"sync":                             "sync",
 			"time":                             "time",
+			"unicode/utf8":                     "utf8",
 		},
 		Interfaces: map[string]reflect.Type{
-			"Transport": reflect.TypeOf((*q.Transport)(nil)).Elem(),
+			"Executor":           reflect.TypeOf((*q.Executor)(nil)).Elem(),
+			"MemoryStore":        reflect.TypeOf((*q.MemoryStore)(nil)).Elem(),
+			"StreamingTransport": reflect.TypeOf((*q.StreamingTransport)(nil)).Elem(),
+			"Tokenizer":          reflect.TypeOf((*q.Tokenizer)(nil)).Elem(),
+			"Transport":          reflect.TypeOf((*q.Transport)(nil)).Elem(),
 		},
 		NamedTypes: map[string]reflect.Type{
-			"ArchivedHistory":      reflect.TypeOf((*q.ArchivedHistory)(nil)).Elem(),
-			"CommandParamSpec":     reflect.TypeOf((*q.CommandParamSpec)(nil)).Elem(),
-			"CommandResult":        reflect.TypeOf((*q.CommandResult)(nil)).Elem(),
-			"CommandSpec":          reflect.TypeOf((*q.CommandSpec)(nil)).Elem(),
-			"Player":               reflect.TypeOf((*q.Player)(nil)).Elem(),
-			"Request":              reflect.TypeOf((*q.Request)(nil)).Elem(),
-			"Response":             reflect.TypeOf((*q.Response)(nil)).Elem(),
-			"TooManyRequestsError": reflect.TypeOf((*q.TooManyRequestsError)(nil)).Elem(),
-			"Turn":                 reflect.TypeOf((*q.Turn)(nil)).Elem(),
+			"ArchiveEvent":             reflect.TypeOf((*q.ArchiveEvent)(nil)).Elem(),
+			"ArchivedHistory":          reflect.TypeOf((*q.ArchivedHistory)(nil)).Elem(),
+			"CommandCall":              reflect.TypeOf((*q.CommandCall)(nil)).Elem(),
+			"CommandHandlerPanicError": reflect.TypeOf((*q.CommandHandlerPanicError)(nil)).Elem(),
+			"CommandParamSpec":         reflect.TypeOf((*q.CommandParamSpec)(nil)).Elem(),
+			"CommandResult":            reflect.TypeOf((*q.CommandResult)(nil)).Elem(),
+			"CommandSpec":              reflect.TypeOf((*q.CommandSpec)(nil)).Elem(),
+			"EndReason":                reflect.TypeOf((*q.EndReason)(nil)).Elem(),
+			"ExecutedCommand":          reflect.TypeOf((*q.ExecutedCommand)(nil)).Elem(),
+			"GoroutineExecutor":        reflect.TypeOf((*q.GoroutineExecutor)(nil)).Elem(),
+			"InteractionPolicy":        reflect.TypeOf((*q.InteractionPolicy)(nil)).Elem(),
+			"Outcome":                  reflect.TypeOf((*q.Outcome)(nil)).Elem(),
+			"Player":                   reflect.TypeOf((*q.Player)(nil)).Elem(),
+			"QueueMode":                reflect.TypeOf((*q.QueueMode)(nil)).Elem(),
+			"QuotaExceededError":       reflect.TypeOf((*q.QuotaExceededError)(nil)).Elem(),
+			"RateLimiter":              reflect.TypeOf((*q.RateLimiter)(nil)).Elem(),
+			"Request":                  reflect.TypeOf((*q.Request)(nil)).Elem(),
+			"Response":                 reflect.TypeOf((*q.Response)(nil)).Elem(),
+			"Scheduler":                reflect.TypeOf((*q.Scheduler)(nil)).Elem(),
+			"SpxExecutor":              reflect.TypeOf((*q.SpxExecutor)(nil)).Elem(),
+			"StatusError":              reflect.TypeOf((*q.StatusError)(nil)).Elem(),
+			"StreamError":              reflect.TypeOf((*q.StreamError)(nil)).Elem(),
+			"ThinkHandle":              reflect.TypeOf((*q.ThinkHandle)(nil)).Elem(),
+			"TokenizerFunc":            reflect.TypeOf((*q.TokenizerFunc)(nil)).Elem(),
+			"TooManyRequestsError":     reflect.TypeOf((*q.TooManyRequestsError)(nil)).Elem(),
+			"TransportError":           reflect.TypeOf((*q.TransportError)(nil)).Elem(),
+			"Turn":                     reflect.TypeOf((*q.Turn)(nil)).Elem(),
 		},
 		AliasTypes: map[string]reflect.Type{},
 		Vars: map[string]reflect.Value{
-			"Break":              reflect.ValueOf(&q.Break),
-			"ErrTransportNotSet": reflect.ValueOf(&q.ErrTransportNotSet),
+			"Break":                    reflect.ValueOf(&q.Break),
+			"ErrBusy":                  reflect.ValueOf(&q.ErrBusy),
+			"ErrCanceled":              reflect.ValueOf(&q.ErrCanceled),
+			"ErrDropped":               reflect.ValueOf(&q.ErrDropped),
+			"ErrInvalidCommandArgs":    reflect.ValueOf(&q.ErrInvalidCommandArgs),
+			"ErrInvalidResponse":       reflect.ValueOf(&q.ErrInvalidResponse),
+			"ErrMaxTurnsExceeded":      reflect.ValueOf(&q.ErrMaxTurnsExceeded),
+			"ErrMemoryNotFound":        reflect.ValueOf(&q.ErrMemoryNotFound),
+			"ErrMemoryStoreNotSet":     reflect.ValueOf(&q.ErrMemoryStoreNotSet),
+			"ErrNoCommand":             reflect.ValueOf(&q.ErrNoCommand),
+			"ErrRateLimitWaitExceeded": reflect.ValueOf(&q.ErrRateLimitWaitExceeded),
+			"ErrSuperseded":            reflect.ValueOf(&q.ErrSuperseded),
+			"ErrTransportNotSet":       reflect.ValueOf(&q.ErrTransportNotSet),
 		},
 		Funcs: map[string]reflect.Value{
-			"DefaultKnowledgeBase":    reflect.ValueOf(q.DefaultKnowledgeBase),
-			"DefaultTransport":        reflect.ValueOf(q.DefaultTransport),
-			"PlayerOnCmd_":            reflect.ValueOf(q.PlayerOnCmd_),
-			"RetryAfterFromHeader":    reflect.ValueOf(q.RetryAfterFromHeader),
-			"SetDefaultKnowledgeBase": reflect.ValueOf(q.SetDefaultKnowledgeBase),
-			"SetDefaultTransport":     reflect.ValueOf(q.SetDefaultTransport),
+			"DefaultExecutor":             reflect.ValueOf(q.DefaultExecutor),
+			"DefaultInteractionPolicy":    reflect.ValueOf(q.DefaultInteractionPolicy),
+			"DefaultKnowledgeBase":        reflect.ValueOf(q.DefaultKnowledgeBase),
+			"DefaultMemoryStore":          reflect.ValueOf(q.DefaultMemoryStore),
+			"DefaultRateLimiter":          reflect.ValueOf(q.DefaultRateLimiter),
+			"DefaultScheduler":            reflect.ValueOf(q.DefaultScheduler),
+			"DefaultTokenizer":            reflect.ValueOf(q.DefaultTokenizer),
+			"DefaultTransport":            reflect.ValueOf(q.DefaultTransport),
+			"IsQuotaExceeded":             reflect.ValueOf(q.IsQuotaExceeded),
+			"NewRateLimiter":              reflect.ValueOf(q.NewRateLimiter),
+			"NewScheduler":                reflect.ValueOf(q.NewScheduler),
+			"PlayerOnCmd_":                reflect.ValueOf(q.PlayerOnCmd_),
+			"QuotaRetryAfter":             reflect.ValueOf(q.QuotaRetryAfter),
+			"RetryAfterFromHeader":        reflect.ValueOf(q.RetryAfterFromHeader),
+			"SetDefaultExecutor":          reflect.ValueOf(q.SetDefaultExecutor),
+			"SetDefaultInteractionPolicy": reflect.ValueOf(q.SetDefaultInteractionPolicy),
+			"SetDefaultKnowledgeBase":     reflect.ValueOf(q.SetDefaultKnowledgeBase),
+			"SetDefaultMemoryStore":       reflect.ValueOf(q.SetDefaultMemoryStore),
+			"SetDefaultRateLimiter":       reflect.ValueOf(q.SetDefaultRateLimiter),
+			"SetDefaultScheduler":         reflect.ValueOf(q.SetDefaultScheduler),
+			"SetDefaultTokenizer":         reflect.ValueOf(q.SetDefaultTokenizer),
+			"SetDefaultTransport":         reflect.ValueOf(q.SetDefaultTransport),
+			"SummarizeLocally":            reflect.ValueOf(q.SummarizeLocally),
+		},
+		TypedConsts: map[string]ixgo.TypedConst{
+			"EndReasonBreak":       {Typ: reflect.TypeOf(q.EndReasonBreak), Value: constant.MakeInt64(int64(q.EndReasonBreak))},
+			"EndReasonError":       {Typ: reflect.TypeOf(q.EndReasonError), Value: constant.MakeInt64(int64(q.EndReasonError))},
+			"EndReasonMaxTurns":    {Typ: reflect.TypeOf(q.EndReasonMaxTurns), Value: constant.MakeInt64(int64(q.EndReasonMaxTurns))},
+			"EndReasonNoCommand":   {Typ: reflect.TypeOf(q.EndReasonNoCommand), Value: constant.MakeInt64(int64(q.EndReasonNoCommand))},
+			"QueueLatestWins":      {Typ: reflect.TypeOf(q.QueueLatestWins), Value: constant.MakeInt64(int64(q.QueueLatestWins))},
+			"QueueRejectWhileBusy": {Typ: reflect.TypeOf(q.QueueRejectWhileBusy), Value: constant.MakeInt64(int64(q.QueueRejectWhileBusy))},
+			"QueueSerial":          {Typ: reflect.TypeOf(q.QueueSerial), Value: constant.MakeInt64(int64(q.QueueSerial))},
 		},
-		TypedConsts: map[string]ixgo.TypedConst{},
 		UntypedConsts: map[string]ixgo.UntypedConst{
-			"GopPackage": {"untyped bool", constant.MakeBool(bool(q.GopPackage))},
+			"GopPackage":                     {"untyped bool", constant.MakeBool(bool(q.GopPackage))},
+			"SnapshotVersion":                {Typ: "untyped int", Value: constant.MakeInt64(int64(q.SnapshotVersion))},
+			"StreamErrorReasonQuotaExceeded": {Typ: "untyped string", Value: constant.MakeString(string(q.StreamErrorReasonQuotaExceeded))},
+			"StreamErrorReasonRateLimited":   {Typ: "untyped string", Value: constant.MakeString(string(q.StreamErrorReasonRateLimited))},
 		},
 	})
 }